@@ -0,0 +1,215 @@
+package admin
+
+import (
+	"backend/game"
+	"backend/logging"
+	. "backend/tools"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Embed the dashboard template into the application so that the admin
+// area doesn't need any files on disk
+//
+//go:embed dashboard.html
+var dashboardSource string
+
+// dashboardTemplate The parsed template for the admin dashboard page
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardSource))
+
+// StateNames The display names for each of the game states
+var StateNames = map[State]string{
+	game.Waiting:      "Waiting",
+	game.Starting:     "Starting",
+	game.Started:      "Started",
+	game.Stopped:      "Stopped",
+	game.DoesNotExist: "Does Not Exist",
+}
+
+type (
+	// GameInfo A structure representing the information about a game that is
+	// shown to server operators
+	GameInfo struct {
+		Id          Identifier   `json:"id"`           // The game code of the game
+		Title       string       `json:"title"`        // The title of the game
+		HostAddress string       `json:"host_address"` // The remote address of the host
+		State       State        `json:"state"`        // The current state of the game
+		StateName   string       `json:"state_name"`   // The display name of the state
+		Question    int          `json:"question"`     // The current question index or -1 if there is none
+		Questions   int          `json:"questions"`    // The total number of questions
+		Players     []PlayerInfo `json:"players"`      // The players in the game
+		Spectators  int          `json:"spectators"`   // The number of spectators watching the game
+		Uptime      int64        `json:"uptime"`       // The time since the game was created in seconds
+	}
+
+	// PlayerInfo A structure representing the information about a player that is
+	// shown to server operators
+	PlayerInfo struct {
+		Id      Identifier `json:"id"`      // The id of the player
+		Name    string     `json:"name"`    // The name of the player
+		Address string     `json:"address"` // The remote address of the player
+		Score   uint32     `json:"score"`   // The current score of the player
+	}
+)
+
+// Handler creates the http handler for the admin area. All requests must be
+// authenticated using HTTP basic auth with the provided token as the password
+func Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin", onDashboard)
+	mux.HandleFunc("/admin/", onDashboard)
+	mux.HandleFunc("/admin/games", onGames)
+	mux.HandleFunc("/admin/stop", onStop)
+	mux.HandleFunc("/admin/kick", onKick)
+	return authenticate(token, mux)
+}
+
+// authenticate wraps the provided handler, only allowing requests through
+// that provide a matching token using either HTTP basic auth or a bearer token
+func authenticate(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok { // If basic auth wasn't provided fallback to the bearer token
+			header := r.Header.Get("Authorization")
+			if len(header) > 7 && header[:7] == "Bearer " {
+				password, ok = header[7:], true
+			}
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(token)) != 1 {
+			logging.Event("admin_unauthorized").With("address", r.RemoteAddr).Warn("Rejected unauthenticated admin request")
+			w.Header().Set("WWW-Authenticate", `Basic realm="Quizler Admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Browsers will send basic auth credentials along with cross site form
+		// submissions so actions are only allowed from the same origin
+		if origin := r.Header.Get("Origin"); r.Method == http.MethodPost && origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CollectGames collects the information about all the current games ordered
+// from the oldest game to the newest
+func CollectGames() []GameInfo {
+	games := game.All()
+	out := make([]GameInfo, len(games))
+	for i, g := range games { // Iterate over the games
+		info := GameInfo{
+			Id:          g.Id,
+			Title:       g.Title,
+			HostAddress: g.HostAddress,
+			State:       g.State,
+			StateName:   StateNames[g.State],
+			Question:    -1,
+			Questions:   len(g.Questions),
+			Players:     []PlayerInfo{},
+			Spectators:  g.SpectatorCount(),
+			Uptime:      int64(time.Since(g.CreatedTime).Seconds()),
+		}
+		if q := g.ActiveQuestion; q != nil { // If the game has an active question
+			info.Question = q.Index
+		}
+		g.Players.ForEach(func(id Identifier, player *game.Player) {
+			info.Players = append(info.Players, PlayerInfo{
+				Id:      id,
+				Name:    player.Name,
+				Address: player.Address,
+				Score:   player.GetScore(),
+			})
+		})
+		sort.Slice(info.Players, func(i, j int) bool {
+			return info.Players[i].Name < info.Players[j].Name
+		})
+		out[i] = info
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Uptime > out[j].Uptime
+	})
+	return out
+}
+
+// onDashboard Handles displaying the admin dashboard page
+func onDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	err := dashboardTemplate.Execute(w, CollectGames())
+	if err != nil { // If we failed to render the dashboard
+		logging.Event("admin_dashboard").Error("Failed to render admin dashboard: %s", err)
+	}
+}
+
+// onGames Handles listing all the games as JSON
+func onGames(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, CollectGames())
+}
+
+// onStop Handles force stopping a game. The game is found using the id
+// form value
+func onStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	g := game.Get(r.FormValue("id")) // Retrieve the game
+	if g == nil {                    // If the game doesn't exist
+		http.Error(w, "That game code doesn't exist", http.StatusNotFound)
+		return
+	}
+	g.Close("Game stopped by server") // Stop the game and disconnect everyone
+	g.Log("admin_stop").Info("Admin force stopped game '%s'", g.Title)
+	respond(w, r)
+}
+
+// onKick Handles kicking a player from a game. The game and player are
+// found using the id and player form values
+func onKick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	g := game.Get(r.FormValue("id")) // Retrieve the game
+	if g == nil {                    // If the game doesn't exist
+		http.Error(w, "That game code doesn't exist", http.StatusNotFound)
+		return
+	}
+	p := g.Players.Get(r.FormValue("player")) // Retrieve the player
+	if p == nil {                             // If the player doesn't exist
+		http.Error(w, "That player doesn't exist", http.StatusNotFound)
+		return
+	}
+	g.Kick(p, "Kicked from game by server") // Kick the player from the game
+	g.Log("admin_kick").WithPlayer(p.Id).Info("Admin kicked player '%s'", p.Name)
+	respond(w, r)
+}
+
+// respond Responds to a successful action either by redirecting back to the
+// dashboard for form submissions or with a JSON success response
+func respond(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("redirect") != "" { // If the action came from the dashboard
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	} else {
+		writeJSON(w, http.StatusOK, struct {
+			Ok bool `json:"ok"`
+		}{Ok: true})
+	}
+}
+
+// writeJSON Writes the provided value as a JSON response with the status code
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil { // If we failed to write the response
+		logging.Event("admin_response").Error("Failed to write admin response: %s", err)
+	}
+}
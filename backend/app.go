@@ -1,14 +1,27 @@
 package main
 
 import (
+	"backend/admin"
+	"backend/certs"
+	"backend/config"
 	"backend/game"
+	"backend/guard"
+	"backend/logging"
+	"backend/metrics"
+	"backend/names"
 	. "backend/net"
 	"backend/tools"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	_ "embed"
 	"fmt"
 	"github.com/jacobtread/gowsps"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 const (
@@ -18,7 +31,7 @@ const (
   /  \ |  | |  / |    |__  |__) 
   \__X \__/ | /_ |___ |___ |  \   by Jacobtread
   
-  Version %s    Server Started on http://localhost:%s
+  Version %s    Server Started on %s://localhost:%d
 
   - Even smaller than last time. :O
 
@@ -33,11 +46,39 @@ const (
 var appIndex []byte
 
 func main() {
-	address := tools.EnvOrDefault("QUIZLER_ADDRESS", "0.0.0.0") // Retrieve the address environment variable
-	port := tools.EnvOrDefault("QUIZLER_PORT", "8080")          // Retrieve the port environment variable
-	host := fmt.Sprintf("%s:%s", address, port)                 // Create a host url from ADDRESS:PORT
+	args := os.Args[1:]
+	printConfig := len(args) > 0 && args[0] == "print-config"
+	if printConfig { // The print-config command accepts the same flags as the server
+		args = args[1:]
+	}
+
+	// Load the config from the config file, environment variables and flags
+	cfg, err := config.Load(args)
+	if err != nil { // If the config couldn't be loaded or was invalid
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig { // Print the effective config and exit
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	// Configure the logging level and output format
+	err = logging.Configure(cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil { // If the logging configuration was invalid
+		logging.Event("startup").Fatal("Invalid logging configuration: %s", err)
+	}
+	game.Configure(cfg)
+	if err := names.Configure(cfg); err != nil { // If the name blocklist couldn't be loaded
+		logging.Event("startup").Fatal("Failed to load name blocklist: %s", err)
+	}
+	guard.Configure(cfg)
 
-	fmt.Printf(Intro, Version, port) // Print the intro message
+	scheme := "http"
+	if cfg.TLS.CertFile != "" {
+		scheme = "https"
+	}
+	fmt.Printf(Intro, Version, scheme, cfg.Server.Port) // Print the intro message
 
 	// Create a handler for handling http requests
 	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
@@ -49,74 +90,416 @@ func main() {
 		}
 	})
 
-	err := http.ListenAndServe(host, nil) // Listen on the provided address
-	if err != nil {                       // If we encountered an error
-		log.Fatal("An error occurred", err) // Print out the error
+	// Create a handler for downloading the results of finished games
+	http.HandleFunc("/results", ResultsDownload)
+
+	// Create a handler for the Prometheus metrics. Requests must provide
+	// the metrics token as a bearer token if one is set
+	http.Handle("/metrics", metrics.Handler(cfg.Access.MetricsToken))
+
+	// The admin area is only enabled when a token is provided
+	if cfg.Access.AdminToken != "" {
+		adminHandler := admin.Handler(cfg.Access.AdminToken)
+		http.Handle("/admin", adminHandler)
+		http.Handle("/admin/", adminHandler)
+	}
+
+	// Enable snapshots of games when using the disk storage backend
+	if cfg.Storage.Backend == config.DiskBackend {
+		if err := game.StartSnapshots(cfg.Storage.Path, cfg.Storage.SnapshotInterval); err != nil {
+			logging.Event("startup").Fatal("Failed to start snapshots: %s", err)
+		}
+	}
+
+	host := cfg.Host()
+	server := &http.Server{Addr: host}
+	if cfg.TLS.CertFile != "" { // Serve over HTTPS if a certificate was provided
+		if cfg.TLS.SelfSigned { // Generate the development certificate if it doesn't exist
+			if err := certs.GenerateSelfSigned(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+				logging.Event("startup").Fatal("Failed to generate self-signed certificate: %s", err)
+			}
+		}
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil { // If the certificate couldn't be loaded
+			logging.Event("startup").Fatal("Failed to load certificate: %s", err)
+		}
+		server.TLSConfig = &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+	go func() {
+		var err error
+		if server.TLSConfig != nil { // The certificate is provided by the reloader
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe() // Listen on the provided address
+		}
+		if err != nil && err != http.ErrServerClosed { // If we encountered an error
+			logging.Event("startup").Fatal("Failed to serve on %s: %s", host, err) // Print out the error
+		}
+	}()
+
+	// Wait for the process to be told to stop
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	go func() { // A second signal stops the server without waiting for the games
+		<-signals
+		logging.Event("shutdown").Warn("Forced to stop before the games finished")
+		os.Exit(1)
+	}()
+
+	// Notify all the games and give them time to finish before stopping the server
+	game.Shutdown(cfg.Server.ShutdownGrace)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil { // If the server didn't shut down cleanly
+		logging.Event("shutdown").Error("Failed to shutdown server: %s", err)
+	}
+	logging.Event("shutdown").Info("Server stopped")
+}
+
+// ResultsDownload Handles downloading the results of a finished game as either
+// CSV or JSON. The results are found using the token sent to the host when the
+// game ended and the format is chosen with the format query parameter
+func ResultsDownload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	results := game.GetResults(query.Get("token")) // Retrieve the results for the token
+	if results == nil {                            // If there are no results for that token
+		http.Error(w, "Results not found or expired", http.StatusNotFound)
+		return
+	}
+	var err error
+	switch query.Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"quizler-%s.json\"", results.Id))
+		err = results.WriteJSON(w)
+	case "csv", "":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"quizler-%s.csv\"", results.Id))
+		err = results.WriteCSV(w)
+	default:
+		http.Error(w, "Unknown results format", http.StatusBadRequest)
+		return
+	}
+	if err != nil { // If we failed to write the results
+		logging.Event("results_download").WithGame(results.Id).Error("Failed to write results: %s", err)
 	}
 }
 
+// ThrottleCloseDelay The time to wait after telling a client they are
+// being disconnected for exceeding the rate limit before closing the socket
+const ThrottleCloseDelay = 500 * time.Millisecond
+
 // SocketState A structure representing the state of a socket instance
 type SocketState struct {
-	Hosted *game.Game   // The hosted player
-	Game   *game.Game   // The active game
-	Player *game.Player // The active player
+	Hosted     *game.Game   // The game this socket created or resumed as the host
+	Game       *game.Game   // The active game
+	Player     *game.Player // The active player
+	Spectating *game.Game   // The game being watched as a spectator
+	Presenting *game.Game   // The game being shown as the presenter display
+	Address    string       // The remote address of the connection
+	IP         string       // The IP of the connection used for limits
+
+	Limiter *guard.Limiter // The rate limiter for the packets of the connection
+	Lookups *guard.Lookups // The failed game codes, passwords and tokens of the connection
+	Socket  *guard.Socket  // The socket used to close the connection
 
 	*gowsps.Connection // The websocket connection
 }
 
 // SocketConnect Creates a socket connection and upgrades the HTTP request to WS
 func SocketConnect(w http.ResponseWriter, r *http.Request) {
+	if !guard.IsOriginAllowed(r) { // Reject connections from origins that aren't allowed
+		logging.Event("origin_rejected").With("address", r.RemoteAddr).With("origin", r.Header.Get("Origin")).Warn("Rejected websocket connection")
+		metrics.ConnectionsRejected.Inc("origin")
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	ip := guard.ClientIP(r)
+	if reason := guard.Acquire(ip); reason != "" { // Reject connections over the connection limits
+		logging.Event("connection_rejected").With("address", guard.ClientAddress(r)).With("reason", reason).Warn("Rejected websocket connection")
+		metrics.ConnectionsRejected.Inc(reason)
+		http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	defer guard.Release(ip)
 	s := gowsps.NewPacketSystem()
-	var state = SocketState{} // Create a new state with the connection
+	var state = SocketState{ // Create a new state with the connection
+		Address: guard.ClientAddress(r),
+		IP:      ip,
+		Limiter: guard.NewLimiter(ip),
+		Lookups: guard.NewLookups(ip),
+		Socket:  guard.NewSocket(w),
+	}
 
 	// Add handlers for each of
-	gowsps.AddHandler(s, CCreateGame, state.onCreateGame)
-	gowsps.AddHandler(s, CCheckNameTaken, state.onCheckNameTaken)
-	gowsps.AddHandler(s, CRequestGameState, state.onRequestGameState)
-	gowsps.AddHandler(s, CRequestJoin, state.onRequestJoin)
-	gowsps.AddHandler(s, CStateChange, state.onStateChange)
-	gowsps.AddHandler(s, CAnswer, state.onAnswer)
-	gowsps.AddHandler(s, CKick, state.onKick)
-
-	s.UpgradeAndListen(w, r, func(conn *gowsps.Connection, err error) {
+	AddHandler(s, &state, CCreateGame, state.onCreateGame)
+	AddHandler(s, &state, CCheckNameTaken, state.onCheckNameTaken)
+	AddHandler(s, &state, CRequestGameState, state.onRequestGameState)
+	AddHandler(s, &state, CRequestJoin, state.onRequestJoin)
+	AddHandler(s, &state, CStateChange, state.onStateChange)
+	AddHandler(s, &state, CAnswer, state.onAnswer)
+	AddHandler(s, &state, CKick, state.onKick)
+	AddHandler(s, &state, CResume, state.onResume)
+	AddHandler(s, &state, CSetPassword, state.onSetPassword)
+	AddHandler(s, &state, CApprove, state.onApprove)
+	AddHandler(s, &state, CRename, state.onRename)
+	AddHandler(s, &state, CSpectate, state.onSpectate)
+	AddHandler(s, &state, CPresent, state.onPresent)
+	AddHandler(s, &state, CSetTeam, state.onSetTeam)
+	AddHandler(s, &state, CPromote, state.onPromote)
+	AddHandler(s, &state, CTransferHost, state.onTransferHost)
+	AddHandler(s, &state, CUnban, state.onUnban)
+
+	metrics.SocketsConnected.Inc()
+	s.UpgradeAndListen(state.Socket, r, func(conn *gowsps.Connection, err error) {
 		state.Connection = conn
 	})
+	metrics.SocketsConnected.Dec()
 
 	state.Cleanup() // Cleanup the state
 }
 
-// Cleanup Stops any hosted games by the state and removes the player
-// from any games if the player isn't the host
+// AddHandler Adds the packet handler to the packet system wrapped so that
+// each received packet is recorded in the incoming packet metrics and packets
+// over the rate limit are throttled instead of being handled
+func AddHandler[T any](s *gowsps.PacketSystem, state *SocketState, id int, handler func(data *T)) {
+	label := metrics.PacketLabel(id)
+	gowsps.AddHandler(s, id, func(data *T) {
+		metrics.PacketsIn.Inc(label)
+		if !state.Limiter.Allow(id) { // If the packet is over the rate limit
+			state.Throttle(id)
+			return
+		}
+		handler(data)
+	})
+}
+
+// Throttle Tells the client that the packet with the provided id was over the
+// rate limit. Clients that keep going over the limit are disconnected
+func (state *SocketState) Throttle(id int) {
+	metrics.PacketsThrottled.Inc(metrics.PacketLabel(id))
+	if state.Limiter.Exceeded() { // If the client has been throttled too many times
+		state.Log("rate_limit").With("packet", metrics.PacketLabel(id)).Warn("Disconnecting client for exceeding the rate limit")
+		state.Send(DisconnectPacket("Disconnected for sending too many requests"))
+		time.AfterFunc(ThrottleCloseDelay, state.Socket.Close) // Give the disconnect packet time to send
+	} else {
+		state.Log("rate_limit").With("packet", metrics.PacketLabel(id)).Debug("Throttled packet")
+		state.Send(ErrorPacket(CodeRateLimited, "You're doing that too fast, please slow down"))
+	}
+}
+
+// Log creates a new log entry for the provided event type which carries the
+// id of the game and player for this socket along with its address
+func (state *SocketState) Log(event string) *logging.Entry {
+	entry := logging.Event(event).With("address", state.Address)
+	if state.Hosted != nil { // If the socket is hosting a game
+		entry.WithGame(state.Hosted.Id)
+	} else if state.Game != nil { // If the socket is playing in a game
+		entry.WithGame(state.Game.Id)
+	}
+	if state.Player != nil { // If the socket has an active player
+		entry.WithPlayer(state.Player.Id)
+	}
+	return entry
+}
+
+// Send sends the provided packet to the socket connection
+func (state *SocketState) Send(packet gowsps.Packet) {
+	Send(state.Connection, packet)
+}
+
+// Cleanup Leaves any hosted games (handing them to a co-host or stopping
+// them), removes the player from any games if the player isn't the host
+// (self-paced players keep their place) and stops spectating and presenting
 func (state *SocketState) Cleanup() {
 	if state.Hosted != nil {
-		state.Hosted.Stop()
+		state.Hosted.LeaveHost(state.Connection)
 		state.Hosted = nil
 	}
 	if state.Game != nil && state.Player != nil {
-		state.Game.RemovePlayer(state.Player)
+		if !state.Game.LeaveHost(state.Connection) { // Players promoted to co-host are no longer players
+			state.Game.PlayerLeft(state.Player)
+		}
 		state.Game = nil
 		state.Player = nil
 	}
+	if state.Spectating != nil {
+		state.Spectating.RemoveSpectator(state.Connection)
+		state.Spectating = nil
+	}
+	if state.Presenting != nil {
+		state.Presenting.DetachPresenter(state.Connection)
+		state.Presenting = nil
+	}
+}
+
+// InGame Checks whether the client is already hosting, playing, spectating or
+// presenting a game. Games that have stopped, that the client was kicked from
+// or that another presenter display replaced the client in are forgotten
+// first so the client can go on to another game
+func (state *SocketState) InGame() bool {
+	if state.Hosted != nil && state.Hosted.State == game.Stopped {
+		state.Hosted = nil
+	}
+	if state.Game != nil && (state.Game.State == game.Stopped || !state.Game.HasMember(state.Connection, state.Player)) {
+		state.Game = nil
+		state.Player = nil
+	}
+	if state.Spectating != nil && state.Spectating.State == game.Stopped {
+		state.Spectating = nil
+	}
+	if state.Presenting != nil && (state.Presenting.State == game.Stopped || !state.Presenting.IsPresenter(state.Connection)) {
+		state.Presenting = nil
+	}
+	return state.Hosted != nil || state.Game != nil || state.Spectating != nil || state.Presenting != nil
+}
+
+// Owned Retrieves the game that the socket is the host (owner) of or nil if
+// the socket isn't hosting a game. Players can become the host when the host
+// is transferred to them
+func (state *SocketState) Owned() *game.Game {
+	for _, g := range []*game.Game{state.Hosted, state.Game} {
+		if g != nil && g.IsHost(state.Connection) {
+			return g
+		}
+	}
+	return nil
+}
+
+// Controlled Retrieves the game that the socket is allowed to control with the
+// provided permission as either the host or a co-host. Returns nil if the
+// socket doesn't have permission
+func (state *SocketState) Controlled(permission game.Permission) *game.Game {
+	for _, g := range []*game.Game{state.Hosted, state.Game} {
+		if g != nil && g.Can(state.Connection, permission) {
+			return g
+		}
+	}
+	return nil
+}
+
+// FindGame Retrieves the game with the provided code. Codes that don't exist
+// are recorded against the connection and IP of the client which are locked
+// out from finding games after trying too many. Returns nil and whether the
+// client is locked out if the game isn't found. Locked out clients are sent
+// an error
+func (state *SocketState) FindGame(code string) (*game.Game, bool) {
+	if state.Lookups.IsLockedOut(guard.CodeFailure) { // If the client tried too many codes
+		state.Send(ErrorPacket(CodeLockedOut, "Too many invalid game codes, please try again later"))
+		return nil, true
+	}
+	g := game.Get(code)
+	if g == nil && state.Lookups.RecordFailure(guard.CodeFailure) { // If this lookup locked the client out
+		state.Log("lookup_lockout").With("code", code).Warn("Locked out client after too many invalid game codes")
+	}
+	return g, false
+}
+
+// RecordWrongSecret Records a wrong password or presenter token for the game
+// against the connection and IP of the client. These have their own lockout
+// so that they don't use up the attempts at entering a game code
+func (state *SocketState) RecordWrongSecret(g *game.Game, what string) {
+	if state.Lookups.RecordFailure(guard.SecretFailure) { // If this attempt locked the client out
+		state.Log("lookup_lockout").WithGame(g.Id).Warn("Locked out client after too many wrong %s", what)
+	}
 }
 
 // onCreateGame Packet handler function for the net.CCreateGame packet. Handles
 // the creation of new games
 func (state *SocketState) onCreateGame(data *CreateGameData) {
-	g := game.New(state.Connection, data.Title, data.Questions) // Create a new game
-	state.Hosted = g                                            // Set the hosted game for this state
-	state.Send(JoinGamePacket(true, g.Id, g.Title))             // Tell the host they've joined the new game as owner
-	state.Send(GameStatePacket(game.Waiting))                   // Tell the player the game state is waiting
-	log.Printf("Created new game '%s' (%s)", g.Title, g.Id)
+	if state.InGame() { // If the client is already in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Already in a game"))
+		return
+	}
+	if game.IsShuttingDown() { // Don't allow new games while shutting down
+		state.Send(ErrorPacket(CodeUnavailable, "The server is restarting, please try again shortly"))
+		return
+	}
+	if err := game.CheckQuestions(data.Questions); err != nil { // If the questions aren't valid
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+		return
+	}
+	if len(data.Password) > game.MaxPasswordLength { // If the password is too long
+		state.Send(ErrorPacket(CodeInvalid, fmt.Sprintf("The password can't be longer than %d characters", game.MaxPasswordLength)))
+		return
+	}
+	teams, err := game.CheckTeams(data.Teams) // Clean the team names for team games
+	if err != nil {
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+		return
+	}
+	scoring, err := game.CheckTeamScoring(data.TeamScoring, data.Consensus)
+	if err != nil {
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+		return
+	}
+	var opens, closes time.Time
+	if data.SelfPaced { // Check the window that self-paced games are open for
+		if opens, closes, err = game.CheckWindow(data.Opens, data.Closes); err != nil {
+			state.Send(ErrorPacket(CodeInvalid, err.Error()))
+			return
+		}
+		if teams != nil && data.Consensus { // Teammates aren't on the same question in self-paced games
+			state.Send(ErrorPacket(CodeInvalid, "Self-paced games can't require team consensus"))
+			return
+		}
+	}
+	if err := game.CheckPools(data.Questions, data.Pools); err != nil { // If the pools can't be drawn from
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+		return
+	}
+	seed := tools.RandomSeed()
+	if data.Seed != nil { // Use the provided seed so the same questions are drawn every time
+		seed = *data.Seed
+	}
+	questions := game.DrawQuestions(data.Questions, data.Pools, seed)
+	if data.ShuffleQuestions { // Serve the questions in a random order
+		game.ShuffleQuestions(questions, seed)
+	}
+	g := game.New(state.Connection, state.Address, data.Title, questions) // Create a new game
+	if data.Password != "" {                                              // Make the game private if a password was provided
+		g.SetPassword(data.Password)
+	}
+	g.Approval = data.Approval       // Require the host to approve players
+	g.LateJoins = data.LateJoins     // Allow players to join after the game starts
+	g.LateMinimum = data.LateMinimum // Start late joiners with the lowest score
+	if teams != nil {                // Set up the teams for team games
+		g.Teams = teams
+		g.TeamScoring = scoring
+		g.TeamConsensus = data.Consensus
+		g.TeamPick = data.TeamPick
+	}
+	if data.SelfPaced { // Let players work through the questions at their own pace
+		g.MakeSelfPaced(opens, closes)
+	}
+	if data.ShuffleAnswers { // Show each player the answers in their own order
+		g.ShuffleAnswers = true
+	}
+	g.Seed = seed                                                // Kept in the results so the same questions can be drawn again
+	state.Hosted = g                                             // Set the hosted game for this state
+	state.Send(JoinGamePacket(true, g.Id, g.Title, g.HostToken)) // Tell the host they've joined the new game as owner
+	state.Send(PresenterTokenPacket(g.PresenterToken))           // Give the host the token for attaching a presenter display
+	state.Send(GameStatePacket(game.Waiting))                    // Tell the player the game state is waiting
+	g.SendAssignment(state.Send, true)                           // Give the host of a self-paced game its times and results token
+	state.Log("game_create").Info("Created new game '%s'", g.Title)
 }
 
 // onCheckNameTaken Packet handler function for the net.CCheckNameTaken packet. Handles
 // checking whether a name is already in use.
 func (state *SocketState) onCheckNameTaken(data *CheckNameTakenData) {
-	g := game.Get(data.Id) // Retrieve the game
-	if g == nil {          // If the game doesn't exist
-		state.Send(ErrorPacket("That game code doesn't exist"))
+	g, locked := state.FindGame(data.Id) // Retrieve the game
+	if locked {
+		return
+	} else if g == nil { // If the game doesn't exist
+		state.Send(ErrorPacket(CodeNotFound, "That game code doesn't exist"))
+	} else if name, err := names.Check(data.Name); err != nil { // If the name isn't allowed
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
 	} else {
-		taken := g.IsNameTaken(data.Name)        // Check if the name is taken
+		taken := g.IsNameTaken(name)             // Check if the name is taken
 		state.Send(NameTakenResultPacket(taken)) // Send the result
 	}
 }
@@ -125,9 +508,11 @@ func (state *SocketState) onCheckNameTaken(data *CheckNameTakenData) {
 // retrieving and sending the player the state of a game. Will send back game.DoesNotExist
 // if the game is not found
 func (state *SocketState) onRequestGameState(data *RequestGameStateData) {
-	log.Printf("Client requested game state for '%s'", data.Id)
-	g := game.Get(data.Id)
-	if g == nil { // If the game doesn't exist
+	state.Log("game_state_request").With("code", data.Id).Debug("Client requested game state")
+	g, locked := state.FindGame(data.Id)
+	if locked {
+		return
+	} else if g == nil { // If the game doesn't exist
 		state.Send(GameStatePacket(game.DoesNotExist))
 	} else {
 		// Send the current game state
@@ -138,19 +523,55 @@ func (state *SocketState) onRequestGameState(data *RequestGameStateData) {
 // onRequestJoin Packet handler function for the net.CRequestJoin packet. Handles
 // client requests to join a game. Sent by a client which wishes to join a game
 func (state *SocketState) onRequestJoin(data *RequestJoinData) {
-	g := game.Get(data.Id) // Retrieve the game with that ID
-	if g == nil {
-		state.Send(ErrorPacket("That game code doesn't exist"))
+	if state.InGame() { // If the client is already in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Already in a game"))
+		return
+	}
+	g, locked := state.FindGame(data.Id) // Retrieve the game with that ID
+	if locked {
+		return
+	} else if g == nil {
+		state.Send(ErrorPacket(CodeNotFound, "That game code doesn't exist"))
 	} else {
-		if g.State != game.Waiting { // If the game isn't in waiting state
-			log.Printf("%d", g.State)
-			state.Send(ErrorPacket("That game is already started"))
-		} else if g.IsNameTaken(data.Name) { // If the name is already taken
-			state.Send(ErrorPacket("That name is already in use"))
+		name, nameErr := names.Check(data.Name) // Clean the name and check it is allowed
+		team := game.NoTeam
+		if data.Team != nil && g.TeamPick { // Use the team the player picked
+			team = *data.Team
+		}
+		if g.SelfPaced && g.State == game.Waiting { // If the self-paced game isn't open yet
+			state.Send(ErrorPacket(CodeState, "That game hasn't opened yet"))
+		} else if !g.CanJoin() { // If the game has started and doesn't allow late joins
+			g.Log("join_rejected").With("address", state.Address).With("state", g.State).Debug("Rejected join, game already started")
+			state.Send(ErrorPacket(CodeState, "That game is already started"))
+		} else if data.Password == "" && g.HasPassword() { // If the player hasn't been asked for the password yet
+			state.Send(ErrorPacket(CodePassword, "That game requires a password"))
+		} else if data.Password != "" && state.Lookups.IsLockedOut(guard.SecretFailure) { // If the client tried too many passwords
+			state.Send(ErrorPacket(CodeLockedOut, "Too many wrong passwords, please try again later"))
+		} else if !g.CheckPassword(data.Password) { // If the password is wrong
+			failedName := name
+			if nameErr != nil { // Don't pass names that aren't allowed on to the host
+				failedName = game.HiddenName
+			}
+			g.JoinFailed(failedName, state.Address)
+			state.RecordWrongSecret(g, "passwords")
+			state.Send(ErrorPacket(CodePassword, "That password is incorrect"))
+		} else if g.Players.Count()+g.Players.PendingCount() >= game.MaxPlayers { // If the game is full
+			state.Send(ErrorPacket(CodeFull, "That game is full"))
+		} else if nameErr != nil { // If the name isn't allowed
+			state.Send(ErrorPacket(CodeInvalid, nameErr.Error()))
+		} else if g.IsBanned(state.IP, name) { // If the player was banned from the game
+			g.Log("join_rejected").With("address", state.Address).Debug("Rejected join, player is banned")
+			state.Send(ErrorPacket(CodeBanned, "You have been banned from this game"))
+		} else if g.IsNameTaken(name) { // If the name is already taken
+			state.Send(ErrorPacket(CodeNameTaken, "That name is already in use"))
+		} else if team != game.NoTeam && !g.IsTeam(team) { // If the picked team doesn't exist
+			state.Send(ErrorPacket(CodeNotFound, "That team doesn't exist"))
+		} else if g.Approval { // If the host must approve players
+			state.Player = g.RequestJoin(state.Connection, state.Address, name, team) // Wait for approval and set the active player
+			state.Game = g                                                            // Set the active game
 		} else {
-			state.Player = g.Join(state.Connection, data.Name) // Join and set the active player
-			state.Game = g                                     // Set the active game
-			state.Send(JoinGamePacket(false, g.Id, g.Title))   // Tell the host they've joined the new game as a player
+			state.Player = g.Join(state.Connection, state.Address, name, team) // Join and set the active player
+			state.Game = g                                                     // Set the active game
 		}
 	}
 }
@@ -160,29 +581,52 @@ func (state *SocketState) onRequestJoin(data *RequestJoinData) {
 // question. Basically a general packet for small changes between the client server
 // that requires no additional data
 func (state *SocketState) onStateChange(data *StateChangeData) {
-	hosted := state.Hosted
 	switch data.State {
 	case CDisconnect: // If the client asked to disconnect from the game
-		log.Printf("Client disconnected")
+		state.Log("leave").Info("Client left the game")
 		state.Cleanup() // Cleanup the state (stop games and remove player)
 	case CStart: // If the client told the server to start the game
+		hosted := state.Controlled(game.StartPermission)
 		if hosted == nil { // If the player is not hosting a game
-			state.Send(ErrorPacket("Failed to update game state. You aren't hosting one?"))
+			state.Send(ErrorPacket(CodeNotAllowed, "Failed to update game state. You aren't hosting one?"))
+		} else if hosted.SelfPaced { // Self-paced games open on their own
+			state.Send(ErrorPacket(CodeState, "Self-paced games start at their open time"))
 		} else if hosted.State != game.Waiting { // If the game is already started
-			state.Send(ErrorPacket("Game is already started/starting"))
+			state.Send(ErrorPacket(CodeState, "Game is already started/starting"))
 		} else {
 			hosted.Start() // Start the game
 		}
 	case CSkip: // If the client told the server to skip the current question (host only)
+		hosted := state.Controlled(game.SkipPermission)
 		if hosted == nil { // If the hosted game doesn't exist
-			state.Send(ErrorPacket("Failed to update game state. You aren't hosting one?"))
+			state.Send(ErrorPacket(CodeNotAllowed, "Failed to update game state. You aren't hosting one?"))
+		} else if hosted.SelfPaced { // Each player moves on at their own pace
+			state.Send(ErrorPacket(CodeState, "Questions can't be skipped in self-paced games"))
 		} else if hosted.State != game.Started { // If the game is not in the started state
-			state.Send(ErrorPacket("Game is not started"))
+			state.Send(ErrorPacket(CodeState, "Game is not started"))
+		} else if hosted.Paused { // The timings can't be changed while paused
+			state.Send(ErrorPacket(CodeState, "The game is paused"))
 		} else {
 			hosted.SkipQuestion() // Skip the question
 		}
+	case CPause, CUnpause: // If the client told the server to pause or unpause the game
+		hosted := state.Controlled(game.PausePermission)
+		pause := data.State == CPause
+		if hosted == nil { // If the hosted game doesn't exist
+			state.Send(ErrorPacket(CodeNotAllowed, "Failed to update game state. You aren't hosting one?"))
+		} else if hosted.SelfPaced { // Players in self-paced games aren't all on the same question
+			state.Send(ErrorPacket(CodeState, "Self-paced games can't be paused"))
+		} else if hosted.State != game.Starting && hosted.State != game.Started { // If the game isn't running
+			state.Send(ErrorPacket(CodeState, "Game is not started"))
+		} else if hosted.Paused == pause { // If the game is already paused or unpaused
+			state.Send(ErrorPacket(CodeState, "The game is already paused or unpaused"))
+		} else if pause {
+			hosted.Pause()
+		} else {
+			hosted.Unpause()
+		}
 	default: // If the state change is an unknown state change
-		log.Printf("Don't know how to handle state '%d'", data.State)
+		state.Log("state_change").With("state", data.State).Warn("Don't know how to handle state change")
 	}
 }
 
@@ -193,23 +637,253 @@ func (state *SocketState) onAnswer(data *AnswerData) {
 	g := state.Game
 	player := state.Player
 	if g == nil || player == nil { // If player is not in a  game
-		state.Send(ErrorPacket("Not in a game"))
+		state.Send(ErrorPacket(CodeNotAllowed, "Not in a game"))
+	} else if g.Players.Get(player.Id) != player { // If the player is waiting to join or is now a co-host
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't playing in this game"))
+	} else if g.Paused { // Answers wait until the game is unpaused
+		state.Send(ErrorPacket(CodeState, "The game is paused"))
+	} else if !g.IsAnswerable(player) { // If there isn't a question to answer
+		state.Send(ErrorPacket(CodeState, "There isn't a question to answer."))
 	} else if player.HasAnswered(g) { // If the player has already answered
-		state.Send(ErrorPacket("You have already answered the question."))
-	} else {
-		player.Answer(g, data.Id) // Submit the player answer
+		state.Send(ErrorPacket(CodeState, "You have already answered the question."))
+	} else if !g.SubmitAnswer(player, data.Id) { // If someone in the team already answered
+		state.Send(ErrorPacket(CodeState, "Someone in your team has already answered the question."))
 	}
 }
 
 // onKick Packet handler function for the net.CKick packet. Handles
-// kicking players from the game (Host only)
+// kicking or banning players from the game (Host and co-hosts with permission only)
 func (state *SocketState) onKick(data *KickData) {
-	hosted := state.Hosted // Retrieve the hosted game
-	if hosted != nil {     // Ensure the hosted game exists
+	hosted := state.Controlled(game.KickPermission) // Retrieve the controlled game
+	if hosted != nil {                              // Ensure the hosted game exists
 		p := hosted.Players.Get(data.Id) // Retrieve the player
-		if p != nil {                    // If the player exists
-			hosted.RemovePlayer(p)                           // Remove the player from the game
-			p.Net.Send(DisconnectPacket("Kicked from game")) // Send a disconnect packet to the player
+		if p == nil && data.Ban {        // Players waiting to join can be banned too
+			p = hosted.Players.GetPending(data.Id)
+		}
+		if p == nil { // If the player doesn't exist
+			return
+		} else if data.Ban {
+			hosted.Ban(p, data.BanIP) // Ban the player from the game
+		} else {
+			hosted.Kick(p, "Kicked from game") // Kick the player from the game
+		}
+	}
+}
+
+// onUnban Packet handler function for the net.CUnban packet. Handles lifting
+// the ban on a player (Host and co-hosts with the kick permission only)
+func (state *SocketState) onUnban(data *UnbanData) {
+	hosted := state.Controlled(game.KickPermission)
+	if hosted == nil { // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+	} else if !hosted.Unban(data.Id) { // If the ban doesn't exist
+		state.Send(ErrorPacket(CodeNotFound, "That ban doesn't exist"))
+	}
+}
+
+// onSetPassword Packet handler function for the net.CSetPassword packet. Handles
+// the host changing the password required to join their game while it is waiting
+func (state *SocketState) onSetPassword(data *SetPasswordData) {
+	hosted := state.Owned()
+	if hosted == nil { // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+	} else if hosted.State != game.Waiting { // Players can only join while waiting
+		state.Send(ErrorPacket(CodeState, "The password can only be changed before the game starts"))
+	} else if len(data.Password) > game.MaxPasswordLength { // If the password is too long
+		state.Send(ErrorPacket(CodeInvalid, fmt.Sprintf("The password can't be longer than %d characters", game.MaxPasswordLength)))
+	} else {
+		hosted.SetPassword(data.Password)
+	}
+}
+
+// onApprove Packet handler function for the net.CApprove packet. Handles the
+// host approving or denying players waiting to join their game (Host only)
+func (state *SocketState) onApprove(data *ApproveData) {
+	hosted := state.Owned()
+	if hosted == nil { // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+	} else if !hosted.CanJoin() { // Players can only join while waiting unless late joins are allowed
+		state.Send(ErrorPacket(CodeState, "Players can only be let in before the game starts"))
+	} else if data.All && data.Approve {
+		hosted.ApproveAll()
+	} else if data.All {
+		hosted.DenyAll("The host didn't let you in")
+	} else if data.Approve {
+		if err := hosted.Approve(data.Id); err != nil { // If the player couldn't be approved
+			state.Send(ErrorPacket(CodeInvalid, err.Error()))
+		}
+	} else {
+		hosted.Deny(data.Id, "The host didn't let you in")
+	}
+}
+
+// onRename Packet handler function for the net.CRename packet. Handles the host
+// changing the name of a player (Host only)
+func (state *SocketState) onRename(data *RenameData) {
+	hosted := state.Owned() // Retrieve the hosted game
+	if hosted == nil {      // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+		return
+	}
+	p := hosted.Players.Get(data.Id) // Retrieve the player
+	if p == nil {                    // If the player doesn't exist
+		state.Send(ErrorPacket(CodeNotFound, "That player isn't in the game"))
+	} else if name, err := names.Check(data.Name); err != nil { // If the name isn't allowed
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+	} else if hosted.IsNameTakenExcept(name, p.Id) { // If another player has the name
+		state.Send(ErrorPacket(CodeNameTaken, "That name is already in use"))
+	} else {
+		hosted.Rename(p, name)
+	}
+}
+
+// onSetTeam Packet handler function for the net.CSetTeam packet. Handles the
+// host moving players between teams or balancing the teams and players
+// picking their own team before the game starts
+func (state *SocketState) onSetTeam(data *SetTeamData) {
+	if hosted := state.Owned(); hosted != nil { // If the host is changing the teams
+		if !hosted.HasTeams() {
+			state.Send(ErrorPacket(CodeInvalid, "That game isn't played in teams"))
+		} else if data.Balance { // If the host wants to balance the teams
+			hosted.BalanceTeams()
+		} else if p := hosted.Players.Get(data.Id); p == nil { // If the player doesn't exist
+			state.Send(ErrorPacket(CodeNotFound, "That player isn't in the game"))
+		} else if !hosted.IsTeam(data.Team) {
+			state.Send(ErrorPacket(CodeNotFound, "That team doesn't exist"))
+		} else {
+			hosted.SetTeam(p, data.Team)
 		}
+		return
+	}
+	g := state.Game
+	player := state.Player
+	if g == nil || player == nil { // If player is not in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Not in a game"))
+	} else if !g.HasTeams() || !g.TeamPick { // If players can't pick their team
+		state.Send(ErrorPacket(CodeNotAllowed, "You can't pick your team in this game"))
+	} else if g.State != game.Waiting { // Teams can't be changed by players once the game starts
+		state.Send(ErrorPacket(CodeState, "The game has already started"))
+	} else if !g.IsTeam(data.Team) {
+		state.Send(ErrorPacket(CodeNotFound, "That team doesn't exist"))
+	} else if g.Players.Get(player.Id) == nil { // If the player is still waiting to be approved
+		player.Team = data.Team
+	} else {
+		g.SetTeam(player, data.Team)
+	}
+}
+
+// onPromote Packet handler function for the net.CPromote packet. Handles the
+// host promoting players to co-host or demoting co-hosts (Host only)
+func (state *SocketState) onPromote(data *PromoteData) {
+	hosted := state.Owned()
+	if hosted == nil { // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+	} else if data.Demote {
+		if !hosted.Demote(data.Id) { // If the co-host doesn't exist
+			state.Send(ErrorPacket(CodeNotFound, "That co-host isn't in the game"))
+		}
+	} else if p := hosted.Players.Get(data.Id); p == nil { // If the player doesn't exist
+		state.Send(ErrorPacket(CodeNotFound, "That player isn't in the game"))
+	} else if permissions, err := game.CheckPermissions(data.Permissions); err != nil {
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+	} else if err := hosted.Promote(p, permissions); err != nil {
+		state.Send(ErrorPacket(CodeInvalid, err.Error()))
+	}
+}
+
+// onTransferHost Packet handler function for the net.CTransferHost packet.
+// Handles the host handing ownership of the game to a co-host. The previous
+// host stays on as a co-host (Host only)
+func (state *SocketState) onTransferHost(data *TransferHostData) {
+	hosted := state.Owned()
+	if hosted == nil { // If the player is not hosting a game
+		state.Send(ErrorPacket(CodeNotAllowed, "You aren't hosting a game"))
+	} else if err := hosted.TransferHost(data.Id, true); err != nil {
+		state.Send(ErrorPacket(CodeNotFound, err.Error()))
+	}
+}
+
+// onSpectate Packet handler function for the net.CSpectate packet. Handles
+// clients asking to watch a game as a spectator
+func (state *SocketState) onSpectate(data *SpectateData) {
+	if state.InGame() { // If the client is already in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Already in a game"))
+		return
+	}
+	g, locked := state.FindGame(data.Id) // Retrieve the game with that ID
+	if locked {
+		return
+	} else if g == nil {
+		state.Send(ErrorPacket(CodeNotFound, "That game code doesn't exist"))
+	} else if g.State == game.Stopped { // If the game has already finished
+		state.Send(ErrorPacket(CodeState, "That game has already finished"))
+	} else if g.Approval { // If the host must approve who is in the game
+		state.Send(ErrorPacket(CodeNotAllowed, "That game can't be spectated"))
+	} else if g.IsBanned(state.IP, "") { // If the IP was banned from the game
+		state.Send(ErrorPacket(CodeBanned, "You have been banned from this game"))
+	} else if data.Password != "" && state.Lookups.IsLockedOut(guard.SecretFailure) { // If the client tried too many passwords
+		state.Send(ErrorPacket(CodeLockedOut, "Too many wrong passwords, please try again later"))
+	} else if !g.CheckPassword(data.Password) { // If the password is wrong
+		if data.Password != "" { // Only count attempts where a password was given
+			g.JoinFailed("Spectator", state.Address)
+			state.RecordWrongSecret(g, "passwords")
+		}
+		state.Send(ErrorPacket(CodePassword, "That password is incorrect"))
+	} else if !g.AddSpectator(state.Connection) { // If the game has too many spectators
+		state.Send(ErrorPacket(CodeFull, "That game has too many spectators"))
+	} else {
+		state.Spectating = g // Set the game being watched
+	}
+}
+
+// onPresent Packet handler function for the net.CPresent packet. Handles
+// clients attaching to a game as the presenter display using the presenter
+// token that was given to the host
+func (state *SocketState) onPresent(data *PresentData) {
+	if state.InGame() { // If the client is already in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Already in a game"))
+		return
+	}
+	g, locked := state.FindGame(data.Id) // Retrieve the game with that ID
+	if locked {
+		return
+	} else if g == nil {
+		state.Send(ErrorPacket(CodeNotFound, "That game code doesn't exist"))
+	} else if state.Lookups.IsLockedOut(guard.SecretFailure) { // If the client tried too many tokens
+		state.Send(ErrorPacket(CodeLockedOut, "Too many wrong presenter tokens, please try again later"))
+	} else if subtle.ConstantTimeCompare([]byte(data.Token), []byte(g.PresenterToken)) != 1 { // If the token is wrong
+		state.RecordWrongSecret(g, "presenter tokens")
+		state.Send(ErrorPacket(CodeNotAllowed, "That presenter token is incorrect"))
+	} else if g.State == game.Stopped { // If the game has already finished
+		state.Send(ErrorPacket(CodeState, "That game has already finished"))
+	} else {
+		state.Presenting = g                // Set the game being presented
+		g.AttachPresenter(state.Connection) // Attach as the presenter display
+	}
+}
+
+// onResume Packet handler function for the net.CResume packet. Handles clients
+// resuming their previous session in a game using their session token. This is
+// used to reconnect to games that were restored after the server restarted
+func (state *SocketState) onResume(data *ResumeData) {
+	if state.InGame() { // If the client is already in a game
+		state.Send(ErrorPacket(CodeNotAllowed, "Already in a game"))
+		return
+	}
+	g, locked := state.FindGame(data.Id) // Retrieve the game with that ID
+	if locked {
+		return
+	} else if g == nil {
+		state.Send(ErrorPacket(CodeNotFound, "That game code doesn't exist"))
+	} else if g.IsBannedToken(data.Token) { // If the player was banned from the game
+		state.Send(ErrorPacket(CodeBanned, "You have been banned from this game"))
+	} else if g.ResumeHost(state.Connection, state.Address, data.Token) { // Resume as the host
+		state.Hosted = g // Set the hosted game for this state
+	} else if p := g.Players.GetByToken(data.Token); p != nil && p.Net == nil {
+		state.Game = g                                     // Set the active game
+		state.Player = p                                   // Set the active player
+		g.ResumePlayer(p, state.Connection, state.Address) // Resume as the player
+	} else {
+		state.Send(ErrorPacket(CodeNotAllowed, "That session can't be resumed"))
 	}
 }
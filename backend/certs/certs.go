@@ -0,0 +1,192 @@
+package certs
+
+import (
+	"backend/logging"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadInterval How often the certificate files are checked for changes
+const ReloadInterval = 10 * time.Second
+
+// Reloader A structure which loads a certificate and private key from files
+// and reloads them whenever either of the files change so that renewed
+// certificates are used without restarting the server
+type Reloader struct {
+	CertFile string // The path to the certificate file
+	KeyFile  string // The path to the private key file
+
+	lock     sync.RWMutex     // A lock for accessing the certificate
+	cert     *tls.Certificate // The currently loaded certificate
+	modified time.Time        // The latest modification time of the files when loaded
+}
+
+// NewReloader Creates a new reloader for the provided files, loading the
+// certificate straight away and starting a goroutine to watch for changes
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	reloader := &Reloader{CertFile: certFile, KeyFile: keyFile}
+	if err := reloader.Load(); err != nil {
+		return nil, err
+	}
+	go reloader.Watch()
+	return reloader, nil
+}
+
+// lastModified Retrieves the latest modification time of the files
+func (reloader *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{reloader.CertFile, reloader.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Load Loads the certificate and private key from the files
+func (reloader *Reloader) Load() error {
+	modified, err := reloader.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(reloader.CertFile, reloader.KeyFile)
+	if err != nil {
+		return err
+	}
+	reloader.lock.Lock()
+	reloader.cert = &cert
+	reloader.modified = modified
+	reloader.lock.Unlock()
+	return nil
+}
+
+// Watch Checks the files for changes every ReloadInterval and reloads the
+// certificate when they change. If the new files can't be loaded the previous
+// certificate continues to be used
+func (reloader *Reloader) Watch() {
+	for {
+		time.Sleep(ReloadInterval)
+		modified, err := reloader.lastModified()
+		reloader.lock.RLock()
+		changed := err == nil && modified.After(reloader.modified)
+		reloader.lock.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := reloader.Load(); err != nil { // If the changed files couldn't be loaded
+			logging.Event("tls_reload").Error("Failed to reload certificate, keeping the previous one: %s", err)
+		} else {
+			logging.Event("tls_reload").Info("Reloaded certificate from %s", reloader.CertFile)
+		}
+	}
+}
+
+// GetCertificate Retrieves the currently loaded certificate. This is used as
+// the GetCertificate function of the tls.Config
+func (reloader *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+	return reloader.cert, nil
+}
+
+// GenerateSelfSigned Creates a self-signed certificate for local development
+// valid for localhost and the machine hostname, writing the certificate and
+// private key to the provided files. Existing files are left alone unless the
+// certificate has expired. Returns an error if only one of the files exists
+// so that a certificate or key that wasn't generated isn't overwritten
+func GenerateSelfSigned(certFile string, keyFile string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if (certErr == nil) != (keyErr == nil) { // If only one of the files exists
+		return fmt.Errorf("only one of %s and %s exists, remove it or provide both", certFile, keyFile)
+	}
+	if certErr == nil { // If both files already exist
+		expired, err := isExpired(certFile)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			return nil
+		}
+		logging.Event("tls_generate").Info("Self-signed certificate at %s has expired, generating a new one", certFile)
+	}
+	return writeSelfSigned(certFile, keyFile, time.Now().AddDate(1, 0, 0))
+}
+
+// isExpired Checks whether the certificate in the provided file has expired
+func isExpired(certFile string) (bool, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return false, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return false, fmt.Errorf("%s doesn't contain a certificate", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, err
+	}
+	return time.Now().After(cert.NotAfter), nil
+}
+
+// writeSelfSigned Writes a new self-signed certificate that expires at the
+// provided time and its private key to the provided files
+func writeSelfSigned(certFile string, keyFile string, notAfter time.Time) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Quizler Development"}, CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return err
+	}
+	logging.Event("tls_generate").Warn("Generated a self-signed certificate at %s for development only", certFile)
+	return nil
+}
@@ -0,0 +1,63 @@
+package certs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// certPaths creates the paths for a certificate and key in a temporary directory
+func certPaths(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	return filepath.Join(dir, "dev.crt"), filepath.Join(dir, "dev.key")
+}
+
+func TestGenerateSelfSignedKeepsExisting(t *testing.T) {
+	certFile, keyFile := certPaths(t)
+	if err := GenerateSelfSigned(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(certFile)
+	if err := GenerateSelfSigned(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(certFile)
+	if !bytes.Equal(before, after) {
+		t.Error("a valid certificate was replaced")
+	}
+	if _, err := NewReloader(certFile, keyFile); err != nil {
+		t.Errorf("the generated certificate couldn't be loaded: %s", err)
+	}
+}
+
+func TestGenerateSelfSignedOneFile(t *testing.T) {
+	certFile, keyFile := certPaths(t)
+	if err := os.WriteFile(keyFile, []byte("my key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateSelfSigned(certFile, keyFile); err == nil {
+		t.Error("expected an error when only the key exists")
+	}
+	if data, _ := os.ReadFile(keyFile); string(data) != "my key" {
+		t.Error("the existing key was overwritten")
+	}
+}
+
+func TestGenerateSelfSignedExpired(t *testing.T) {
+	certFile, keyFile := certPaths(t)
+	if err := writeSelfSigned(certFile, keyFile, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateSelfSigned(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := isExpired(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired {
+		t.Error("the expired certificate wasn't regenerated")
+	}
+}
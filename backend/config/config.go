@@ -0,0 +1,378 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Config A structure representing the configuration of the server. Each
+	// value can be set in the config file using the toml key, overridden by the
+	// environment variable in the env tag and then by the command line flag
+	// named after its section and key (e.g. -server.port)
+	Config struct {
+		Server    ServerConfig    `toml:"server"`
+		Timing    TimingConfig    `toml:"timing"`
+		Limits    LimitsConfig    `toml:"limits"`
+		Access    AccessConfig    `toml:"access"`
+		RateLimit RateLimitConfig `toml:"rate_limit"`
+		Names     NamesConfig     `toml:"names"`
+		TLS       TLSConfig       `toml:"tls"`
+		Storage   StorageConfig   `toml:"storage"`
+		Logging   LoggingConfig   `toml:"logging"`
+	}
+
+	// ServerConfig Configuration for the http server
+	ServerConfig struct {
+		Address       string        `toml:"address" env:"QUIZLER_ADDRESS"`               // The address to bind on
+		Port          int           `toml:"port" env:"QUIZLER_PORT"`                     // The port to bind on
+		ShutdownGrace time.Duration `toml:"shutdown_grace" env:"QUIZLER_SHUTDOWN_GRACE"` // The time games are given to finish when stopping
+	}
+
+	// TimingConfig Configuration for the default timings of games
+	TimingConfig struct {
+		StartDelay   time.Duration `toml:"start_delay" env:"QUIZLER_START_DELAY"`     // The time to wait before starting the game
+		QuestionTime time.Duration `toml:"question_time" env:"QUIZLER_QUESTION_TIME"` // The time to display each question for
+		SyncDelay    time.Duration `toml:"sync_delay" env:"QUIZLER_SYNC_DELAY"`       // The delay to wait between each time sync
+		MarkTime     time.Duration `toml:"mark_time" env:"QUIZLER_MARK_TIME"`         // The time to display the marking screen for
+		BonusTime    time.Duration `toml:"bonus_time" env:"QUIZLER_BONUS_TIME"`       // The time the player can earn a bonus score within
+	}
+
+	// LimitsConfig Configuration for the limits placed on games
+	LimitsConfig struct {
+		MaxPlayers          int    `toml:"max_players" env:"QUIZLER_MAX_PLAYERS"`                       // The maximum number of players in a game
+		MaxSpectators       int    `toml:"max_spectators" env:"QUIZLER_MAX_SPECTATORS"`                 // The maximum number of spectators watching a game
+		MaxQuestions        int    `toml:"max_questions" env:"QUIZLER_MAX_QUESTIONS"`                   // The maximum number of questions in a game
+		MaxAnswers          int    `toml:"max_answers" env:"QUIZLER_MAX_ANSWERS"`                       // The maximum number of answers for a question
+		MaxImageSize        int    `toml:"max_image_size" env:"QUIZLER_MAX_IMAGE_SIZE"`                 // The maximum size of a question image in bytes
+		GameCodeLength      int    `toml:"game_code_length" env:"QUIZLER_GAME_CODE_LENGTH"`             // The number of characters in game codes
+		GameCodeAlphabet    string `toml:"game_code_alphabet" env:"QUIZLER_GAME_CODE_ALPHABET"`         // The chars or named alphabet for game codes
+		MaxConnections      int    `toml:"max_connections" env:"QUIZLER_MAX_CONNECTIONS"`               // The maximum number of connected sockets
+		MaxConnectionsPerIP int    `toml:"max_connections_per_ip" env:"QUIZLER_MAX_CONNECTIONS_PER_IP"` // The maximum number of sockets from one IP, 0 for no limit
+		MaxMessageSize      int    `toml:"max_message_size" env:"QUIZLER_MAX_MESSAGE_SIZE"`             // The maximum size of a client message in bytes
+	}
+
+	// AccessConfig Configuration for who can access the server
+	AccessConfig struct {
+		AllowedOrigins []string `toml:"allowed_origins" env:"QUIZLER_ALLOWED_ORIGINS"`           // The origins allowed to connect to the websocket
+		AdminToken     string   `toml:"admin_token" env:"QUIZLER_ADMIN_TOKEN" secret:"true"`     // The password for the admin dashboard
+		MetricsToken   string   `toml:"metrics_token" env:"QUIZLER_METRICS_TOKEN" secret:"true"` // The bearer token for the metrics
+
+		TrustedProxies []string `toml:"trusted_proxies" env:"QUIZLER_TRUSTED_PROXIES"` // The IPs or CIDR ranges of proxies trusted to set X-Forwarded-For
+	}
+
+	// RateLimitConfig Configuration for limiting how often clients can send
+	// packets. Rates are written as a number of packets per duration (e.g. 5/1m)
+	RateLimitConfig struct {
+		Enabled        bool          `toml:"enabled" env:"QUIZLER_RATE_LIMIT"`                 // Whether packets are rate limited
+		CreateGame     string        `toml:"create_game" env:"QUIZLER_RATE_CREATE_GAME"`       // The rate of creating games
+		CheckName      string        `toml:"check_name" env:"QUIZLER_RATE_CHECK_NAME"`         // The rate of checking whether names are taken
+		GameState      string        `toml:"game_state" env:"QUIZLER_RATE_GAME_STATE"`         // The rate of requesting game states
+		Join           string        `toml:"join" env:"QUIZLER_RATE_JOIN"`                     // The rate of requesting to join games
+		Other          string        `toml:"other" env:"QUIZLER_RATE_OTHER"`                   // The rate of all other packets
+		IPMultiplier   int           `toml:"ip_multiplier" env:"QUIZLER_RATE_IP_MULTIPLIER"`   // How many times the rates are allowed for each IP
+		MaxViolations  int           `toml:"max_violations" env:"QUIZLER_RATE_MAX_VIOLATIONS"` // The throttled packets before a client is disconnected
+		ViolationDecay time.Duration `toml:"violation_decay" env:"QUIZLER_VIOLATION_DECAY"`    // How long it takes for one violation to be forgiven
+		LookupFailures int           `toml:"lookup_failures" env:"QUIZLER_LOOKUP_FAILURES"`    // The wrong game codes or passwords a connection can try within the window
+		LookupWindow   time.Duration `toml:"lookup_window" env:"QUIZLER_LOOKUP_WINDOW"`        // The window that failed lookups are counted within
+		LookupLockout  time.Duration `toml:"lookup_lockout" env:"QUIZLER_LOOKUP_LOCKOUT"`      // How long a connection or IP is locked out for
+
+		LookupIPFailures int `toml:"lookup_ip_failures" env:"QUIZLER_LOOKUP_IP_FAILURES"` // The wrong game codes or passwords all connections from an IP can try within the window
+	}
+
+	// NamesConfig Configuration for the names players can use
+	NamesConfig struct {
+		MinLength     int    `toml:"min_length" env:"QUIZLER_NAME_MIN_LENGTH"`    // The minimum number of characters in a name
+		MaxLength     int    `toml:"max_length" env:"QUIZLER_NAME_MAX_LENGTH"`    // The maximum number of characters in a name
+		BlocklistFile string `toml:"blocklist_file" env:"QUIZLER_NAME_BLOCKLIST"` // The file of words that aren't allowed in names
+	}
+
+	// TLSConfig Configuration for serving over HTTPS
+	TLSConfig struct {
+		CertFile   string `toml:"cert_file" env:"QUIZLER_TLS_CERT"`          // The path to the certificate file
+		KeyFile    string `toml:"key_file" env:"QUIZLER_TLS_KEY"`            // The path to the private key file
+		SelfSigned bool   `toml:"self_signed" env:"QUIZLER_TLS_SELF_SIGNED"` // Whether to generate a self-signed certificate
+	}
+
+	// StorageConfig Configuration for where game data is stored
+	StorageConfig struct {
+		Backend          string        `toml:"backend" env:"QUIZLER_STORAGE_BACKEND"`             // The storage backend either memory or disk
+		Path             string        `toml:"path" env:"QUIZLER_SNAPSHOT_DIR"`                   // The directory to store snapshots in for the disk backend
+		SnapshotInterval time.Duration `toml:"snapshot_interval" env:"QUIZLER_SNAPSHOT_INTERVAL"` // How often snapshots are saved
+		RestoreTimeout   time.Duration `toml:"restore_timeout" env:"QUIZLER_RESTORE_TIMEOUT"`     // How long the host of a restored game has to reconnect
+		ResultsLifetime  time.Duration `toml:"results_lifetime" env:"QUIZLER_RESULTS_LIFETIME"`   // How long game results are kept for
+	}
+
+	// LoggingConfig Configuration for the logging output
+	LoggingConfig struct {
+		Level  string `toml:"level" env:"QUIZLER_LOG_LEVEL"`   // The minimum level of log messages
+		Format string `toml:"format" env:"QUIZLER_LOG_FORMAT"` // The format of log messages
+	}
+)
+
+// The different storage backends
+const (
+	MemoryBackend = "memory" // Games are only kept in memory
+	DiskBackend   = "disk"   // Games are snapshotted to disk and restored on startup
+)
+
+// The named alphabets that can be used for game codes
+const (
+	HexAlphabet          = "hex"          // The digits and A to F
+	NumericAlphabet      = "numeric"      // Only the digits
+	AlphanumericAlphabet = "alphanumeric" // The digits and A to Z
+	FriendlyAlphabet     = "friendly"     // The digits and A to Z without chars that are easily confused
+)
+
+// CodeAlphabets The chars for each of the named alphabets
+var CodeAlphabets = map[string]string{
+	HexAlphabet:          "0123456789ABCDEF",
+	NumericAlphabet:      "0123456789",
+	AlphanumericAlphabet: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	FriendlyAlphabet:     "23456789ABCDEFGHJKMNPQRSTUVWXYZ",
+}
+
+// MinGameCodes The fewest possible game codes allowed by the game code
+// alphabet and length so that codes are hard to guess
+const MinGameCodes = 1e6
+
+// CodeAlphabet Resolves the chars for the provided game code alphabet which
+// is either the name of one of the CodeAlphabets or the chars themselves
+func CodeAlphabet(alphabet string) string {
+	if chars, exists := CodeAlphabets[strings.ToLower(alphabet)]; exists {
+		return chars
+	}
+	return alphabet
+}
+
+// isCodeAlphabet Checks that the chars are all different and are only digits
+// and uppercase letters. Game codes are shown and typed in uppercase and are
+// used in snapshot file names so nothing else is allowed
+func isCodeAlphabet(chars []rune) bool {
+	seen := map[rune]bool{}
+	for _, c := range chars {
+		if seen[c] || !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+		seen[c] = true
+	}
+	return true
+}
+
+// The files used for the self-signed development certificate when no
+// other files are provided
+const (
+	DevCertFile = "quizler-dev.crt"
+	DevKeyFile  = "quizler-dev.key"
+)
+
+// Default Creates the default configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address: "0.0.0.0",
+			Port:    8080,
+		},
+		Timing: TimingConfig{
+			StartDelay:   5 * time.Second,
+			QuestionTime: 10 * time.Second,
+			SyncDelay:    2 * time.Second,
+			MarkTime:     3 * time.Second,
+			BonusTime:    5 * time.Second,
+		},
+		Limits: LimitsConfig{
+			MaxPlayers:          100,
+			MaxSpectators:       50,
+			MaxQuestions:        100,
+			MaxAnswers:          8,
+			MaxImageSize:        5 * 1024 * 1024,
+			GameCodeLength:      6,
+			GameCodeAlphabet:    FriendlyAlphabet,
+			MaxConnections:      10000,
+			MaxConnectionsPerIP: 0,
+			MaxMessageSize:      16 * 1024 * 1024,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			CreateGame:     "5/1m",
+			CheckName:      "20/10s",
+			GameState:      "10/10s",
+			Join:           "10/10s",
+			Other:          "50/1s",
+			IPMultiplier:   5,
+			MaxViolations:  20,
+			ViolationDecay: 10 * time.Second,
+			LookupFailures: 10,
+			LookupWindow:   time.Minute,
+			LookupLockout:  5 * time.Minute,
+
+			LookupIPFailures: 100,
+		},
+		Names: NamesConfig{
+			MinLength: 1,
+			MaxLength: 16,
+		},
+		Storage: StorageConfig{
+			SnapshotInterval: 10 * time.Second,
+			RestoreTimeout:   2 * time.Minute,
+			ResultsLifetime:  time.Hour,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Host Creates the host address for the server from the address and port
+func (config *Config) Host() string {
+	return fmt.Sprintf("%s:%d", config.Server.Address, config.Server.Port)
+}
+
+// Validate Checks that all the configuration values are usable. Returns an
+// error describing every invalid value if any are invalid. The storage backend
+// is resolved to disk if a path is set and no backend was chosen and the TLS
+// files default to the development files when using a self-signed certificate
+func (config *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(config.Server.Port > 0 && config.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(config.Server.ShutdownGrace >= 0, "server.shutdown_grace must not be negative")
+
+	check(config.Timing.StartDelay > 0, "timing.start_delay must be positive")
+	check(config.Timing.QuestionTime > 0, "timing.question_time must be positive")
+	check(config.Timing.SyncDelay > 0, "timing.sync_delay must be positive")
+	check(config.Timing.MarkTime > 0, "timing.mark_time must be positive")
+	check(config.Timing.BonusTime >= 0, "timing.bonus_time must not be negative")
+
+	check(config.Limits.MaxPlayers > 0, "limits.max_players must be positive")
+	check(config.Limits.MaxSpectators >= 0, "limits.max_spectators can't be negative")
+	check(config.Limits.MaxQuestions > 0, "limits.max_questions must be positive")
+	check(config.Limits.MaxAnswers >= 2, "limits.max_answers must be at least 2")
+	check(config.Limits.MaxImageSize > 0, "limits.max_image_size must be positive")
+	check(config.Limits.GameCodeLength >= 4 && config.Limits.GameCodeLength <= 16, "limits.game_code_length must be between 4 and 16")
+	alphabet := []rune(CodeAlphabet(config.Limits.GameCodeAlphabet))
+	check(len(alphabet) >= 2 && isCodeAlphabet(alphabet), "limits.game_code_alphabet must be a named alphabet or at least 2 different characters from 0-9 and A-Z")
+	check(math.Pow(float64(len(alphabet)), float64(config.Limits.GameCodeLength)) >= MinGameCodes,
+		"limits.game_code_alphabet and limits.game_code_length must allow at least %d different codes", int(MinGameCodes))
+	check(config.Limits.MaxConnections > 0, "limits.max_connections must be positive")
+	check(config.Limits.MaxConnectionsPerIP >= 0, "limits.max_connections_per_ip can't be negative")
+	check(config.Limits.MaxMessageSize > config.Limits.MaxImageSize, "limits.max_message_size must be larger than limits.max_image_size")
+
+	rates := []struct{ key, rate string }{
+		{"rate_limit.create_game", config.RateLimit.CreateGame},
+		{"rate_limit.check_name", config.RateLimit.CheckName},
+		{"rate_limit.game_state", config.RateLimit.GameState},
+		{"rate_limit.join", config.RateLimit.Join},
+		{"rate_limit.other", config.RateLimit.Other},
+	}
+	for _, r := range rates { // Ensure each of the rates can be parsed
+		_, _, err := ParseRate(r.rate)
+		check(err == nil, "%s must be a number per duration (e.g. 5/1m)", r.key)
+	}
+	_, err := ParseProxies(config.Access.TrustedProxies)
+	check(err == nil, "access.trusted_proxies must be IP addresses or CIDR ranges: %v", err)
+	check(config.RateLimit.IPMultiplier > 0, "rate_limit.ip_multiplier must be positive")
+	check(config.RateLimit.MaxViolations > 0, "rate_limit.max_violations must be positive")
+	check(config.RateLimit.ViolationDecay > 0, "rate_limit.violation_decay must be positive")
+	check(config.RateLimit.LookupFailures > 0, "rate_limit.lookup_failures must be positive")
+	check(config.RateLimit.LookupIPFailures >= config.RateLimit.LookupFailures, "rate_limit.lookup_ip_failures must be at least rate_limit.lookup_failures")
+	check(config.RateLimit.LookupWindow > 0, "rate_limit.lookup_window must be positive")
+	check(config.RateLimit.LookupLockout > 0, "rate_limit.lookup_lockout must be positive")
+
+	check(config.Names.MinLength >= 1, "names.min_length must be at least 1")
+	check(config.Names.MaxLength >= config.Names.MinLength && config.Names.MaxLength <= 64, "names.max_length must be between names.min_length and 64")
+
+	if config.TLS.SelfSigned { // Use the default development files if none were provided
+		if config.TLS.CertFile == "" {
+			config.TLS.CertFile = DevCertFile
+		}
+		if config.TLS.KeyFile == "" {
+			config.TLS.KeyFile = DevKeyFile
+		}
+	}
+	check((config.TLS.CertFile == "") == (config.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+
+	if config.Storage.Backend == "" { // Resolve the backend from whether a path was set
+		if config.Storage.Path != "" {
+			config.Storage.Backend = DiskBackend
+		} else {
+			config.Storage.Backend = MemoryBackend
+		}
+	}
+	switch config.Storage.Backend {
+	case MemoryBackend:
+	case DiskBackend:
+		check(config.Storage.Path != "", "storage.path is required for the disk backend")
+		check(config.Storage.SnapshotInterval > 0, "storage.snapshot_interval must be positive")
+		check(config.Storage.RestoreTimeout > 0, "storage.restore_timeout must be positive")
+	default:
+		check(false, "storage.backend must be either %s or %s", MemoryBackend, DiskBackend)
+	}
+	check(config.Storage.ResultsLifetime > 0, "storage.results_lifetime must be positive")
+
+	level := strings.ToLower(config.Logging.Level)
+	check(level == "debug" || level == "info" || level == "warn" || level == "warning" || level == "error",
+		"logging.level must be one of debug, info, warn or error")
+	check(config.Logging.Format == "text" || config.Logging.Format == "json", "logging.format must be either text or json")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// ParseProxies Parses the trusted proxies which are written as either an IP
+// address (e.g. 10.0.0.1) or a CIDR range (e.g. 10.0.0.0/8) into networks
+func ParseProxies(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") { // Single addresses are a network of one
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address '%s'", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy range '%s'", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ParseRate Parses a rate written as a number per duration (e.g. 5/1m) into
+// the number and duration. Both must be positive
+func ParseRate(raw string) (int, time.Duration, error) {
+	parts := strings.SplitN(raw, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid rate '%s'", raw)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate count '%s'", parts[0])
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate duration '%s'", parts[1])
+	}
+	return count, per, nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateGameCodeAlphabet(t *testing.T) {
+	alphabets := map[string]bool{
+		FriendlyAlphabet:   true,
+		"Numeric":          true,
+		"0123456789ABCDEF": true,
+		"ABCDEFGHIJKLMNOP": true,
+		"abcdefghijklmnop": false, // Lowercase codes can't be typed into the frontend
+		"0123456789./AB":   false, // Codes are used in snapshot file names
+		"0123456789 ":      false,
+		"00112233445566":   false,
+		"A":                false,
+	}
+	for alphabet, valid := range alphabets {
+		config := Default()
+		config.Limits.GameCodeAlphabet = alphabet
+		config.Limits.GameCodeLength = 16
+		err := config.Validate()
+		if invalid := err != nil && strings.Contains(err.Error(), "limits.game_code_alphabet"); invalid == valid {
+			t.Errorf("alphabet %q valid = %v, want %v (%v)", alphabet, !invalid, valid, err)
+		}
+	}
+}
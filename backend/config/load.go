@@ -0,0 +1,216 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile The config file that is loaded if it exists and no other
+// config file was provided
+const DefaultFile = "quizler.toml"
+
+// field A structure representing a single configuration value along with
+// the names used to set it
+type field struct {
+	Section string        // The name of the section the value is in
+	Key     string        // The full key of the value (section.name)
+	Env     string        // The environment variable for the value
+	Secret  bool          // Whether the value should be hidden when printed
+	Value   reflect.Value // The settable value
+}
+
+// fields Collects all the configuration values in the order they are declared
+func (config *Config) fields() []field {
+	var out []field
+	root := reflect.ValueOf(config).Elem()
+	for i := 0; i < root.NumField(); i++ { // Iterate over the sections
+		section := root.Type().Field(i).Tag.Get("toml")
+		value := root.Field(i)
+		for j := 0; j < value.NumField(); j++ { // Iterate over the section values
+			tag := value.Type().Field(j).Tag
+			out = append(out, field{
+				Section: section,
+				Key:     section + "." + tag.Get("toml"),
+				Env:     tag.Get("env"),
+				Secret:  tag.Get("secret") == "true",
+				Value:   value.Field(j),
+			})
+		}
+	}
+	return out
+}
+
+// set Parses the raw string and sets it as the field value
+func (f *field) set(raw string) error {
+	switch f.Value.Interface().(type) {
+	case string:
+		f.Value.SetString(raw)
+	case int:
+		value, err := strconv.Atoi(strings.ReplaceAll(raw, "_", ""))
+		if err != nil {
+			return fmt.Errorf("%s: expected a whole number but got '%s'", f.Key, raw)
+		}
+		f.Value.SetInt(int64(value))
+	case bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false but got '%s'", f.Key, raw)
+		}
+		f.Value.SetBool(value)
+	case time.Duration:
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%s: expected a duration (e.g. 10s) but got '%s'", f.Key, raw)
+		}
+		f.Value.SetInt(int64(value))
+	case []string:
+		var values []string
+		for _, value := range strings.Split(raw, ",") { // Split the comma separated values
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		f.Value.Set(reflect.ValueOf(values))
+	}
+	return nil
+}
+
+// setTOML Sets the field value from a value in the config file. Arrays from
+// the config file are used as they are instead of being split on commas
+func (f *field) setTOML(value TOMLValue) error {
+	_, isArray := f.Value.Interface().([]string)
+	if isArray != (value.Array != nil) {
+		if isArray {
+			return fmt.Errorf("%s: expected an array but got '%s'", f.Key, value.Value)
+		}
+		return fmt.Errorf("%s: expected a single value but got an array", f.Key)
+	}
+	if isArray {
+		f.Value.Set(reflect.ValueOf(value.Array))
+		return nil
+	}
+	return f.set(value.Value)
+}
+
+// Load Loads the configuration from the defaults which are overlaid by the
+// config file, then the environment variables and then the command line
+// arguments. The config file can be provided with the -config flag or the
+// QUIZLER_CONFIG environment variable otherwise DefaultFile is used if it
+// exists. The loaded configuration is validated before being returned
+func Load(args []string) (*Config, error) {
+	config := Default()
+	fields := config.fields()
+
+	flags := flag.NewFlagSet("quizler", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("QUIZLER_CONFIG"), "The path to the config file")
+	for _, f := range fields { // Create a flag for each of the values
+		flags.String(f.Key, "", fmt.Sprintf("Sets %s (env %s)", f.Key, f.Env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Load the config file
+	file := *path
+	if file == "" {
+		if _, err := os.Stat(DefaultFile); err == nil { // If the default file exists
+			file = DefaultFile
+		}
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		values, err := ParseTOML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+		}
+		known := map[string]bool{}
+		for _, f := range fields { // Apply the values from the config file
+			known[f.Key] = true
+			value, exists := values[f.Key]
+			if !exists {
+				continue
+			}
+			if err := f.setTOML(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", file, value.Line, err)
+			}
+		}
+		for key, value := range values { // Reject any unknown keys to catch typos
+			if !known[key] {
+				return nil, fmt.Errorf("%s:%d: unknown config key '%s'", file, value.Line, key)
+			}
+		}
+	}
+
+	// Apply the values from the environment variables
+	for _, f := range fields {
+		if raw, exists := os.LookupEnv(f.Env); exists && f.Env != "" {
+			if err := f.set(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Env, err)
+			}
+		}
+	}
+
+	// Apply the values from the command line flags that were provided
+	var err error
+	flags.Visit(func(flag *flag.Flag) {
+		for _, f := range fields {
+			if f.Key == flag.Name && err == nil {
+				err = f.set(flag.Value.String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Print Writes the configuration to the provided writer in the config file
+// format. Secret values are replaced so that they aren't leaked into logs
+func (config *Config) Print(w io.Writer) {
+	section := ""
+	for _, f := range config.fields() { // Iterate over all the values
+		if f.Section != section { // Write the section header when it changes
+			if section != "" {
+				_, _ = fmt.Fprintln(w)
+			}
+			section = f.Section
+			_, _ = fmt.Fprintf(w, "[%s]\n", section)
+		}
+		var value string
+		switch v := f.Value.Interface().(type) {
+		case string:
+			if f.Secret && v != "" {
+				v = "<redacted>"
+			}
+			value = strconv.Quote(v)
+		case int:
+			value = strconv.Itoa(v)
+		case bool:
+			value = strconv.FormatBool(v)
+		case time.Duration:
+			value = strconv.Quote(v.String())
+		case []string:
+			quoted := make([]string, len(v))
+			for i, item := range v {
+				quoted[i] = strconv.Quote(item)
+			}
+			value = "[" + strings.Join(quoted, ", ") + "]"
+		}
+		name := f.Key[len(section)+1:]
+		_, _ = fmt.Fprintf(w, "%s = %s\n", name, value)
+	}
+}
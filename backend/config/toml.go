@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TOMLValue A structure representing a value parsed from a TOML file. Only
+// one of Value or Array is set depending on the type of the value
+type TOMLValue struct {
+	Value string   // The value for strings, numbers and booleans
+	Array []string // The values for arrays
+	Line  int      // The line the value was on
+}
+
+// ParseTOML Parses the subset of TOML used by the config file: [section]
+// headers, key = value pairs, basic and literal strings, numbers, booleans,
+// single line arrays of those values and # comments. Returns a map of
+// section.key to values
+func ParseTOML(source string) (map[string]TOMLValue, error) {
+	out := map[string]TOMLValue{}
+	section := ""
+	for i, line := range strings.Split(source, "\n") { // Iterate over the lines
+		number := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") { // If the line is a section header
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", number)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if !isBareKey(section) {
+				return nil, fmt.Errorf("line %d: invalid section name '%s'", number, section)
+			}
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", number)
+		}
+		key := strings.TrimSpace(line[:eq])
+		raw := strings.TrimSpace(line[eq+1:])
+		if !isBareKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key '%s'", number, key)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, exists := out[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", number, key)
+		}
+		value := TOMLValue{Line: number}
+		if strings.HasPrefix(raw, "[") { // If the value is an array
+			if !strings.HasSuffix(raw, "]") {
+				return nil, fmt.Errorf("line %d: arrays must be on a single line", number)
+			}
+			value.Array = []string{}
+			for _, item := range splitArray(raw[1 : len(raw)-1]) {
+				if strings.HasPrefix(item, "[") {
+					return nil, fmt.Errorf("line %d: nested arrays aren't supported", number)
+				}
+				parsed, err := parseScalar(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", number, err)
+				}
+				value.Array = append(value.Array, parsed)
+			}
+		} else {
+			parsed, err := parseScalar(raw)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			value.Value = parsed
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// parseScalar Parses a single string, number or boolean value
+func parseScalar(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) { // Basic strings support escapes
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(raw, "'") { // Literal strings are taken as they are
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	if raw == "true" || raw == "false" {
+		return raw, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("invalid value %s, strings must be quoted", raw)
+	}
+	return raw, nil
+}
+
+// isBareKey Checks whether the key is made up of only letters, digits,
+// underscores and dashes
+func isBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// scanUnquoted Calls visit with each of the chars in the line that aren't
+// inside of strings until visit returns false
+func scanUnquoted(line string, visit func(i int, c rune) bool) {
+	var quote rune
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped: // Escaped chars can't end a basic string
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && !visit(i, c):
+			return
+		}
+	}
+}
+
+// splitArray Splits the contents of an array on the commas that aren't
+// inside of strings
+func splitArray(raw string) []string {
+	var items []string
+	start := 0
+	scanUnquoted(raw, func(i int, c rune) bool {
+		if c == ',' {
+			items = append(items, raw[start:i])
+			start = i + 1
+		}
+		return true
+	})
+	items = append(items, raw[start:])
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" { // Allow trailing commas
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// stripComment Removes any comment from the end of the line ignoring any
+// # characters that are inside of strings
+func stripComment(line string) string {
+	end := len(line)
+	scanUnquoted(line, func(i int, c rune) bool {
+		if c == '#' {
+			end = i
+			return false
+		}
+		return true
+	})
+	return line[:end]
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLValues(t *testing.T) {
+	values, err := ParseTOML(strings.Join([]string{
+		`top = 1`,
+		`[server]`,
+		`address = "0.0.0.0" # The address`,
+		`hash = "a # b"`,
+		`literal = 'C:\path\'`,
+		`escaped = "say \"hi\" \\"  # The string ends before this comment`,
+		`port = 8_080`,
+		`enabled = true`,
+		``,
+		`  # A comment on its own`,
+		`[access]`,
+		`origins = ["https://a.com", 'https://b.com', "c,d", "e]",]`,
+		`empty = []`,
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scalars := map[string]string{
+		"top":            "1",
+		"server.address": "0.0.0.0",
+		"server.hash":    "a # b",
+		"server.literal": `C:\path\`,
+		"server.escaped": `say "hi" \`,
+		"server.port":    "8_080",
+		"server.enabled": "true",
+	}
+	for key, want := range scalars {
+		if got := values[key]; got.Value != want || got.Array != nil {
+			t.Errorf("%s = %+v, want %q", key, got, want)
+		}
+	}
+	arrays := map[string][]string{
+		"access.origins": {"https://a.com", "https://b.com", "c,d", "e]"},
+		"access.empty":   {},
+	}
+	for key, want := range arrays {
+		if got := values[key].Array; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if line := values["server.port"].Line; line != 7 {
+		t.Errorf("server.port is on line %d, want 7", line)
+	}
+}
+
+func TestParseTOMLInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"unterminated section", "[server"},
+		{"invalid section", "[my server]"},
+		{"missing equals", "address"},
+		{"missing key", "= 1"},
+		{"invalid key", "my key = 1"},
+		{"missing value", "port ="},
+		{"bare string", "level = info"},
+		{"unterminated string", `level = "info`},
+		{"unterminated literal", "level = 'info"},
+		{"trailing value", `level = "info" "debug"`},
+		{"multiline array", "origins = [\n\"a\"]"},
+		{"nested array", "origins = [[1]]"},
+		{"invalid array item", "origins = [a]"},
+		{"duplicate key", "port = 1\nport = 2"},
+	}
+	for _, test := range tests {
+		if _, err := ParseTOML(test.source); err == nil {
+			t.Errorf("%s: expected an error parsing %q", test.name, test.source)
+		}
+	}
+}
+
+func TestLoadArrays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quizler.toml")
+	source := "[access]\ntrusted_proxies = [\"10.0.0.0/8\", \"127.0.0.1\"]\n"
+	if err := os.WriteFile(path, []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "127.0.0.1"}
+	if got := config.Access.TrustedProxies; !reflect.DeepEqual(got, want) {
+		t.Errorf("trusted_proxies = %q, want %q", got, want)
+	}
+}
+
+func TestLoadTypeMismatch(t *testing.T) {
+	tests := []string{
+		"[access]\ntrusted_proxies = \"10.0.0.0/8\"\n", // A string instead of an array
+		"[server]\nport = [8080]\n",                    // An array instead of a number
+	}
+	for _, source := range tests {
+		path := filepath.Join(t.TempDir(), "quizler.toml")
+		if err := os.WriteFile(path, []byte(source), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load([]string{"-config", path}); err == nil {
+			t.Errorf("expected an error loading %q", source)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package game
+
+import (
+	"backend/guard"
+	"backend/names"
+	"backend/net"
+	. "backend/tools"
+	"crypto/subtle"
+	. "github.com/jacobtread/gowsps"
+	"time"
+)
+
+// Ban A structure representing a player that was banned from the game. Bans
+// last for the rest of the game unless the host lifts them
+type Ban struct {
+	Id    Identifier `json:"id"`    // The id the player had when they were banned
+	Name  string     `json:"name"`  // The name the player had when they were banned
+	IP    string     `json:"ip"`    // The IP address the player was connected from
+	Token Identifier `json:"token"` // The session token of the player
+	ByIP  bool       `json:"by_ip"` // Whether anyone connecting from the IP is banned
+	Time  time.Time  `json:"time"`  // The time the player was banned
+}
+
+// Ban kicks the player from the game and records their name, IP and session
+// token so that they can't join again. When byIP is true anyone joining from
+// the same IP is also kept out which should be avoided when players share a
+// network such as in a classroom
+func (game *Game) Ban(player *Player, byIP bool) {
+	ban := &Ban{
+		Id:    player.Id,
+		Name:  player.Name,
+		IP:    guard.AddressIP(player.Address),
+		Token: player.Token,
+		ByIP:  byIP,
+		Time:  time.Now(),
+	}
+	game.BansLock.Lock()
+	if game.Bans == nil {
+		game.Bans = map[Identifier]*Ban{}
+	}
+	game.Bans[ban.Id] = ban
+	game.BansLock.Unlock()
+
+	if !game.Deny(player.Id, "You have been banned from this game") { // Players waiting to join are denied instead
+		game.Kick(player, "You have been banned from this game")
+	}
+	game.SendHost(net.BannedPacket(ban.Id, ban.Name, net.AddMode))
+	game.Log("player_ban").WithPlayer(player.Id).With("by_ip", byIP).Info("Player '%s' banned from '%s'", player.Name, game.Title)
+}
+
+// Unban lifts the ban with the provided id. Returns false if there is no ban
+// with that id
+func (game *Game) Unban(id Identifier) bool {
+	game.BansLock.Lock()
+	ban, exists := game.Bans[id]
+	delete(game.Bans, id)
+	game.BansLock.Unlock()
+	if !exists {
+		return false
+	}
+	game.SendHost(net.BannedPacket(ban.Id, ban.Name, net.RemoveMode))
+	game.Log("player_unban").With("ban", id).Info("Lifted the ban on '%s' in '%s'", ban.Name, game.Title)
+	return true
+}
+
+// IsBanned checks whether a player joining from the provided IP with the
+// provided name has been banned. Names that look alike the banned name
+// count as banned
+func (game *Game) IsBanned(ip string, name string) bool {
+	game.BansLock.RLock()
+	defer game.BansLock.RUnlock()
+	for _, ban := range game.Bans {
+		if ban.ByIP && ban.IP == ip {
+			return true
+		}
+		if name != "" && names.Collides(name, ban.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBannedToken checks whether the provided session token belongs to a
+// player that has been banned
+func (game *Game) IsBannedToken(token Identifier) bool {
+	game.BansLock.RLock()
+	defer game.BansLock.RUnlock()
+	for _, ban := range game.Bans {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(ban.Token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SendBans uses the provided send function to send the bans of the game
+func (game *Game) SendBans(send func(packet Packet)) {
+	game.BansLock.RLock()
+	defer game.BansLock.RUnlock()
+	for _, ban := range game.Bans {
+		send(net.BannedPacket(ban.Id, ban.Name, net.AddMode))
+	}
+}
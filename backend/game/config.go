@@ -0,0 +1,82 @@
+package game
+
+import (
+	"backend/config"
+	. "backend/tools"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits for games. These are the defaults which can be changed by the
+// config using Configure
+var (
+	MaxPlayers     = 100             // The maximum number of players in a game
+	MaxSpectators  = 50              // The maximum number of spectators that can watch a game
+	MaxQuestions   = 100             // The maximum number of questions in a game
+	MaxAnswers     = 8               // The maximum number of answers for a question
+	MaxImageSize   = 5 * 1024 * 1024 // The maximum size of a question image in bytes
+	GameCodeLength = 6               // The number of characters in game codes
+)
+
+// MaxExplanationLength The maximum number of characters in the explanation
+// and host notes of a question
+const MaxExplanationLength = 2000
+
+// GameCodeChars The chars that game codes are made from
+var GameCodeChars = config.CodeAlphabets[config.FriendlyAlphabet]
+
+// Configure Sets the game timings and limits from the provided config
+func Configure(cfg *config.Config) {
+	StartDelay = cfg.Timing.StartDelay
+	QuestionTime = cfg.Timing.QuestionTime
+	SyncDelay = cfg.Timing.SyncDelay
+	MarkTime = cfg.Timing.MarkTime
+	BonusTime = cfg.Timing.BonusTime
+
+	MaxPlayers = cfg.Limits.MaxPlayers
+	MaxSpectators = cfg.Limits.MaxSpectators
+	MaxQuestions = cfg.Limits.MaxQuestions
+	MaxAnswers = cfg.Limits.MaxAnswers
+	MaxImageSize = cfg.Limits.MaxImageSize
+	GameCodeLength = cfg.Limits.GameCodeLength
+	GameCodeChars = config.CodeAlphabet(cfg.Limits.GameCodeAlphabet)
+
+	RestoreTimeout = cfg.Storage.RestoreTimeout
+	ResultsLifetime = cfg.Storage.ResultsLifetime
+}
+
+// CheckQuestions Checks that the provided questions are within the limits
+// and are able to be played. Returns an error describing the first problem
+func CheckQuestions(questions []QuestionData) error {
+	if len(questions) == 0 {
+		return errors.New("The quiz must have at least one question")
+	}
+	if len(questions) > MaxQuestions {
+		return fmt.Errorf("The quiz can't have more than %d questions", MaxQuestions)
+	}
+	for i, question := range questions { // Iterate over the questions
+		number := i + 1
+		if strings.TrimSpace(question.Question) == "" {
+			return fmt.Errorf("Question %d is empty", number)
+		}
+		if len(question.Answers) < 2 || len(question.Answers) > MaxAnswers {
+			return fmt.Errorf("Question %d must have between 2 and %d answers", number, MaxAnswers)
+		}
+		if len(question.Values) == 0 {
+			return fmt.Errorf("Question %d must have a correct answer", number)
+		}
+		for _, value := range question.Values { // Ensure the correct answers exist
+			if value < 0 || value >= len(question.Answers) {
+				return fmt.Errorf("Question %d has an invalid correct answer", number)
+			}
+		}
+		if len(question.Image) > MaxImageSize {
+			return fmt.Errorf("The image for question %d is too large", number)
+		}
+		if len([]rune(question.Explanation)) > MaxExplanationLength || len([]rune(question.Notes)) > MaxExplanationLength {
+			return fmt.Errorf("The explanation and notes for question %d can't be longer than %d characters", number, MaxExplanationLength)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package game
 
 import (
+	"backend/logging"
+	"backend/metrics"
+	"backend/names"
 	"backend/net"
 	. "backend/tools"
 	. "github.com/jacobtread/gowsps"
-	"log"
 	"math"
 	"strings"
 	"sync"
@@ -23,6 +25,9 @@ const (
 // Game a structure representing the game itself
 type Game struct {
 	Host           *Connection     // The connection to the game host
+	HostAddress    string          // The remote address of the game host
+	HostToken      Identifier      // The session token used to resume the host
+	PresenterToken Identifier      // The token used to attach the presenter display
 	Id             Identifier      // The unique identifier / game code for this game
 	Title          string          // The title / name of this game
 	Questions      []QuestionData  // An array of the questions for this game
@@ -30,6 +35,46 @@ type Game struct {
 	StartTime      time.Duration   // The system time in ms of when the game was created
 	State          State           // The current state of the game
 	ActiveQuestion *ActiveQuestion // The currently active question nil by default
+	CreatedTime    time.Time       // The time at which the game was created
+	RestoredAt     time.Duration   // The time the game was restored from a snapshot at while waiting for the host to resume
+	Paused         bool            // Whether the game has been paused by the host
+	PausedAt       time.Duration   // The time the host paused the game at
+	Approval       bool            // Whether players must be approved by the host before joining
+	LateJoins      bool            // Whether players can join after the game has started
+	LateMinimum    bool            // Whether late joiners start with the lowest score instead of zero
+	Password       string          // The password required to join the game, empty for public games
+	FailedJoins    int             // The number of attempts to join with the wrong password
+	PasswordLock   sync.RWMutex    // A lock for the password and failed joins
+	Teams          []string        // The names of the teams, empty for games that aren't played in teams
+	TeamScoring    TeamScoring     // How the scores of the players in a team are combined
+	TeamConsensus  bool            // Whether only the first answer from each team counts
+	TeamPick       bool            // Whether players pick their own team instead of being assigned one
+	TeamsLock      sync.Mutex      // A lock for team assignments and team answers
+
+	SelfPaced    bool       // Whether players answer the questions at their own pace
+	Opens        time.Time  // The time a self-paced game opens for players to join
+	Closes       time.Time  // The time a self-paced game closes and the results are final
+	ResultsToken Identifier // The token for downloading the results of a self-paced game
+
+	ShuffleAnswers bool  // Whether each player is shown the answers in their own order
+	Seed           int64 // The seed the questions were drawn and shuffled with
+
+	Bans     map[Identifier]*Ban // The players banned from the game mapped to their ids
+	BansLock sync.RWMutex        // A lock for the bans
+
+	Left     []*Player  // Players that left or were removed after the game started, kept for the results
+	LeftLock sync.Mutex // A lock for the players that left
+
+	QuestionsSaved bool       // Whether the questions have been written to the snapshot directory
+	SnapshotLock   sync.Mutex // A lock for writing and removing the snapshot of the game
+
+	CoHosts   map[*Connection]*CoHost // The connections the host has given control of the game
+	HostsLock sync.RWMutex            // A lock for the host and co-hosts
+
+	Presenter      *Connection          // The connection to the presenter display if one is attached
+	PresenterLock  sync.RWMutex         // A lock for the presenter
+	Spectators     map[*Connection]bool // The connections watching the game without playing
+	SpectatorsLock sync.RWMutex         // A lock for the spectators
 }
 
 // ActiveQuestion a structure representing the currently served question
@@ -38,6 +83,7 @@ type ActiveQuestion struct {
 	Index     QuestionIndex // The index of this question in the array of questions
 	StartTime time.Duration // The time that this question started at
 	Marked    bool          // Whether the question has been marked
+	MarkedAt  time.Duration // The time the question was marked in self-paced games
 }
 
 // GamesLock A lock for modifying the games map
@@ -47,11 +93,11 @@ var GamesLock = sync.RWMutex{}
 var Games = map[Identifier]*Game{}
 
 // CreateGameId Creates a new game id this will be unique in order to not collided
-// with existing game ids so will iterate CreateRandomId until a unique one is found
+// with existing game ids so will iterate CreateRandomIdFrom until a unique one is found
 func CreateGameId() Identifier {
 	GamesLock.RLock() // Establish a read lock on the games map
 	for {
-		id := CreateRandomId(5)
+		id := CreateRandomIdFrom(GameCodeChars, GameCodeLength)
 		_, contains := Games[id]
 		if !contains { // Check the id doesn't already exist
 			GamesLock.RUnlock() // Release the read lock
@@ -60,10 +106,22 @@ func CreateGameId() Identifier {
 	}
 }
 
-// Get retrieves the game with a matching Identifier or else returns nil
+// NormalizeCode Converts a game code entered by a player to the case used
+// by the game codes. Codes are only case-sensitive when the game code chars
+// contain lowercase letters
+func NormalizeCode(code string) string {
+	code = strings.TrimSpace(code)
+	if strings.ToUpper(GameCodeChars) == GameCodeChars {
+		return strings.ToUpper(code)
+	}
+	return code
+}
+
+// Get retrieves the game with a matching Identifier or else returns nil. The
+// identifier is normalized so that players can enter codes in any case
 func Get(identifier Identifier) *Game {
 	GamesLock.RLock() // Establish a read lock on the games map
-	game, contains := Games[identifier]
+	game, contains := Games[NormalizeCode(identifier)]
 	GamesLock.RUnlock() // Release the read lock
 	if !contains {
 		return nil
@@ -71,59 +129,202 @@ func Get(identifier Identifier) *Game {
 	return game
 }
 
-// New Creates a new game instance with the provided host, title, and questions.
-// also starts a new goroutine for the games loop, adds it to Games and returns
-// a reference to the game
-func New(host *Connection, title string, questions []QuestionData) *Game {
+// All retrieves a copy of all the games that currently exist as an array
+func All() []*Game {
+	GamesLock.RLock() // Establish a read lock on the games map
+	games := make([]*Game, 0, len(Games))
+	for _, game := range Games { // Iterate over the games map
+		games = append(games, game)
+	}
+	GamesLock.RUnlock() // Release the read lock
+	return games
+}
+
+// New Creates a new game instance with the provided host, host address, title, and
+// questions. also starts a new goroutine for the games loop, adds it to Games and
+// returns a reference to the game
+func New(host *Connection, address string, title string, questions []QuestionData) *Game {
 	id := CreateGameId() // Create a new unique game ID
 	game := Game{
-		Host:      host,
-		Id:        id,
-		Title:     title,
-		Questions: questions,
-		Players:   NewPlayerStore(),
-		StartTime: Time(),
-		State:     Waiting,
+		Host:           host,
+		HostAddress:    address,
+		HostToken:      CreateRandomId(16),
+		PresenterToken: CreateRandomId(16),
+		Id:             id,
+		Title:          title,
+		Questions:      questions,
+		Players:        NewPlayerStore(),
+		StartTime:      Time(),
+		State:          Waiting,
+		CreatedTime:    time.Now(),
 	}
 	GamesLock.Lock() // Establish write lock on the games map
 	// Store the game in the games map
 	Games[id] = &game
-	GamesLock.Unlock() // Release write lock
-	go game.Loop()     // Start a new goroutine for the game loop
+	GamesLock.Unlock()         // Release write lock
+	metrics.GamesCreated.Inc() // Record the game in the created games metrics
+	go game.Loop()             // Start a new goroutine for the game loop
 	return &game
 }
 
-// Join adds a new player to the game with the provided connection and name
-// and returns a reference to the player
-func (game *Game) Join(conn *Connection, name string) *Player {
-	player := game.Players.Create(conn, name) // Create a new player
+// Join adds a new player to the game with the provided connection, address,
+// name and picked team and returns a reference to the player
+func (game *Game) Join(conn *Connection, address string, name string, team int) *Player {
+	player := game.Players.NewPlayer(conn, address, name) // Create a new player
+	player.Team = team                                    // Set the team the player picked
+	game.Admit(player)                                    // Add the player to the game
+	return player
+}
+
+// Admit adds the player to the game sending them the state of the game and
+// telling everyone else in the game that they joined
+func (game *Game) Admit(player *Player) {
+	late := game.State != Waiting
+	if late && game.LateMinimum && !game.SelfPaced { // Start late joiners with the lowest score so they aren't too far behind
+		player.Score = game.Players.MinScore()
+	}
+	game.AssignTeam(player)  // Place the player in a team for team games
+	game.Players.Add(player) // Add the player to the player store
 	// Send the initial state of the game
-	player.Net.Send(net.GameStatePacket(game.State))
+	player.Send(net.GameStatePacket(game.State))
 	// Send the player their self player data
-	player.Net.Send(net.PlayerDataPacket(player.Id, player.Name, net.SelfMode))
+	player.Send(net.PlayerDataPacket(player.Id, player.Name, net.SelfMode))
 	// Information all other connections that this new player was added
-	game.BroadcastExcluding(player.Id, net.PlayerDataPacket(player.Id, name, net.AddMode), true)
-	log.Printf("Player '%s' has joined '%s' (%s) given id '%s'", name, game.Title, game.Id, player.Id)
-	return player
+	game.BroadcastExcluding(player.Id, net.PlayerDataPacket(player.Id, player.Name, net.AddMode), true)
+	if game.HasTeams() { // Tell everyone else which team the player is in
+		game.BroadcastExcluding(player.Id, net.PlayerTeamPacket(player.Id, player.Team), true)
+	}
+	// Tell the player they've joined the game as a player
+	player.Send(net.JoinGamePacket(false, game.Id, game.Title, player.Token))
+	game.SendTeams(player.Send) // Send the player the teams and who is in them
+	if late {                   // Bring late joiners up to date with the game
+		game.CatchUp(player)
+	}
+	if game.SelfPaced { // Players in self-paced games start on their first question straight away
+		game.SendAssignment(player.Send, false)
+		game.NextPlayerQuestion(player, Time())
+	}
+	game.Log("player_join").WithPlayer(player.Id).With("late", late).Info("Player '%s' joined '%s'", player.Name, game.Title)
+}
+
+// CanJoin checks whether players are able to join the game in its current
+// state. Players can always join while waiting and can join games that are
+// in progress if the game allows late joins
+func (game *Game) CanJoin() bool {
+	if game.SelfPaced { // Players can join any time while a self-paced game is open
+		return game.State == Started
+	}
+	switch game.State {
+	case Waiting:
+		return true
+	case Starting, Started:
+		return game.LateJoins
+	default:
+		return false
+	}
 }
 
-// IsNameTaken checks the game players to see if any other players already
-// have a matching name (case-insensitive)
+// CatchUp sends a player that joined after the game started the current
+// progress of the game and the scores. The scores are sent to everyone so that
+// they include the new player
+func (game *Game) CatchUp(player *Player) {
+	game.SendProgress(player.Send, player)
+	game.BroadcastScores()
+}
+
+// SendProgress uses the provided send function to send the current countdown
+// and the active question if it can still be answered. Used to bring players
+// and spectators that arrive part way through the game up to date. The player
+// is used to put the answers in their order and is nil for everyone else
+func (game *Game) SendProgress(send func(packet Packet), player *Player) {
+	t := Time()
+	if game.Paused { // Send the timings as they were when the game was paused
+		send(net.PausedPacket(true))
+		t = game.PausedAt
+	}
+	if game.State == Starting { // If the game is still counting down
+		remaining := StartDelay - (t - game.StartTime)
+		send(net.TimeSyncPacket(StartDelay, remaining))
+	} else if q := game.ActiveQuestion; game.State == Started && q != nil && !q.Marked {
+		elapsed := t - q.StartTime
+		if elapsed < QuestionTime { // If answering is still open
+			send(game.QuestionPacketFor(player, q))
+			send(net.TimeSyncPacket(QuestionTime, QuestionTime-elapsed))
+		}
+	}
+}
+
+// IsNameTaken checks the game players and pending players to see if any other
+// players already have a name that looks like the provided name
 func (game *Game) IsNameTaken(name string) bool {
-	return game.Players.AnyMatch(func(player *Player) bool {
-		return strings.EqualFold(player.Name, name)
-	})
+	return game.IsNameTakenExcept(name, "")
+}
+
+// IsNameTakenExcept checks whether any player other than the player with the
+// provided id has a name that looks like the provided name. Names are compared
+// by their skeleton so names using lookalike characters are also taken
+func (game *Game) IsNameTakenExcept(name string, except Identifier) bool {
+	skeleton := names.Skeleton(name)
+	matches := func(player *Player) bool {
+		return player.Id != except && names.Skeleton(player.Name) == skeleton
+	}
+	if game.Players.AnyMatch(matches) {
+		return true
+	}
+	for _, player := range game.Players.GetPendingArray() { // Names of players waiting for approval are also taken
+		if matches(player) {
+			return true
+		}
+	}
+	return false
+}
+
+// Log creates a new log entry for the provided event type which carries
+// the id of this game
+func (game *Game) Log(event string) *logging.Entry {
+	return logging.Event(event).WithGame(game.Id)
+}
+
+// SendHost sends the provided packet to the host of the game if connected
+// and to all the co-hosts
+func (game *Game) SendHost(packet Packet) {
+	game.SendHostExcept(packet)
+}
+
+// SendHostExcept sends the provided packet to the host and co-hosts of the
+// game excluding any of the provided connections
+func (game *Game) SendHostExcept(packet Packet, exclude ...*Connection) {
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	excluded := func(conn *Connection) bool {
+		for _, other := range exclude {
+			if conn == other {
+				return true
+			}
+		}
+		return false
+	}
+	if game.Host != nil && !excluded(game.Host) { // Games restored from snapshots may not have a host yet
+		net.Send(game.Host, packet)
+	}
+	for conn := range game.CoHosts { // Co-hosts are sent everything the host is sent
+		if !excluded(conn) {
+			net.Send(conn, packet)
+		}
+	}
 }
 
 // Broadcast sends the provided packet to all the players in the game
 func (game *Game) Broadcast(packet Packet, host bool) {
 	// Iterate over all the players
 	game.Players.ForEach(func(id Identifier, player *Player) {
-		player.Net.Send(packet) // Send the packet to the player
+		player.Send(packet) // Send the packet to the player
 	})
-	if host { // If this packet should also be sent to the host
+	game.SendSpectators(packet) // Spectators receive everything that is broadcast
+	game.SendPresenter(packet)  // The presenter display receives everything that is broadcast
+	if host {                   // If this packet should also be sent to the host
 		// Send the host the packet as well
-		game.Host.Send(packet)
+		game.SendHost(packet)
 	}
 }
 
@@ -134,25 +335,31 @@ func (game *Game) BroadcastExcluding(exclude Identifier, packet Packet, host boo
 	// Iterate over all the players
 	game.Players.ForEach(func(id Identifier, player *Player) {
 		if id != exclude { // If the player id != the excluded id
-			player.Net.Send(packet)
+			player.Send(packet)
 		}
 	})
-	if host { // If this packet should also be sent to the host
+	game.SendSpectators(packet) // Spectators receive everything that is broadcast
+	game.SendPresenter(packet)  // The presenter display receives everything that is broadcast
+	if host {                   // If this packet should also be sent to the host
 		// Send the host the packet as well
-		game.Host.Send(packet)
+		game.SendHost(packet)
 	}
 }
 
 // Start Marks the game as Starting and begins the startup countdown and
 // time sync on the client's
 func (game *Game) Start() {
-	log.Printf("Game '%s' (%s) moving into starting state", game.Title, game.Id)
+	game.Log("game_start").Info("Game '%s' moving into starting state", game.Title)
+	if !game.LateJoins { // Players waiting to be let in can still join late games
+		game.DenyAll("The game started before you were let in")
+	}
 	game.SetState(Starting)
 	game.StartTime = Time()
 }
 
-// Timing for different events
-const (
+// Timing for different events. These are the defaults which can be
+// changed by the config using Configure
+var (
 	StartDelay   = 5 * time.Second  // The time to wait before starting the game
 	QuestionTime = 10 * time.Second // The time to display each question for
 	SyncDelay    = 2 * time.Second  // The delay to wait between each time sync
@@ -180,6 +387,17 @@ func (game *Game) Loop() {
 			break // break from the game loop
 		}
 
+		if game.Paused { // Nothing moves on while the game is paused
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if game.SelfPaced { // Self-paced games move each player along separately
+			game.SelfPacedTick()
+			time.Sleep(time.Second)
+			continue
+		}
+
 		t := Time()
 
 		// The total time passed since the last time sync
@@ -238,20 +456,14 @@ func (game *Game) Loop() {
 // IsCorrect checks the correct answers for a question and checks if they match
 // the provided answer index
 func (question *ActiveQuestion) IsCorrect(answer AnswerIndex) bool {
-	correctAnswers := question.Question.Values
-	for _, value := range correctAnswers { // Iterate over the correct answers
-		if value == answer { // If it matches
-			return true // The answer is correct
-		}
-	}
-	return false
+	return question.Question.IsCorrect(answer)
 }
 
 // GetScore calculates the score that the player should be given based on how
 // long it took them to answer and the bonus that entails
-func GetScore(player *Player, question *ActiveQuestion) uint32 {
-	// Calculate the time passed from the question start till the player answered
-	passed := player.AnswerTime - question.StartTime
+func GetScore(record *AnswerRecord) uint32 {
+	// Retrieve the time passed from the question start till the player answered
+	passed := record.Latency
 	if passed <= BonusTime { // If the play is within the bonus period
 		// Calculate how far through the bonus they are. This is
 		// inverted because more score is awarded the quicker they go
@@ -264,8 +476,16 @@ func GetScore(player *Player, question *ActiveQuestion) uint32 {
 	}
 }
 
-// HaveAllAnswered checks whether all players have answered the current question
+// HaveAllAnswered checks whether all players have answered the current question.
+// With team consensus a player counts as answered once their team has answered
 func (game *Game) HaveAllAnswered() bool {
+	if game.TeamConsensus {
+		records := game.TeamRecords(game.ActiveQuestion.Index)
+		return game.Players.AllMatch(func(player *Player) bool {
+			_, answered := records[player.Team]
+			return answered
+		})
+	}
 	return game.Players.AllMatch(func(player *Player) bool {
 		return player.HasAnswered(game)
 	})
@@ -282,33 +502,83 @@ func (game *Game) SkipQuestion() {
 	}
 }
 
+// Pause Pauses the game so that the countdown and the active question stop
+// moving on until the game is unpaused
+func (game *Game) Pause() {
+	game.PausedAt = Time()
+	game.Paused = true
+	game.Broadcast(net.PausedPacket(true), true)
+	game.Log("game_pause").Info("Game '%s' paused", game.Title)
+}
+
+// Unpause Moves the timings forward by the time spent paused so that the game
+// carries on from where it was paused
+func (game *Game) Unpause() {
+	paused := Time() - game.PausedAt
+	game.StartTime += paused
+	if q := game.ActiveQuestion; q != nil {
+		q.StartTime += paused
+	}
+	game.Paused = false
+	game.Broadcast(net.PausedPacket(false), true)
+	game.Log("game_unpause").Info("Game '%s' unpaused", game.Title)
+}
+
 // MarkQuestion Marks the question at the end of the
 func (game *Game) MarkQuestion(question *ActiveQuestion) {
-	log.Printf("Marking questions for game '%s' (%s)", game.Title, game.Id)
+	game.Log("question_mark").With("question", question.Index).Debug("Marking question")
+	var answerers map[int]*Player
+	if game.TeamConsensus { // The first answer from each team counts for the whole team
+		answerers = game.TeamAnswerers(question.Index)
+	}
 	game.Players.ForEach(func(id Identifier, player *Player) {
 		// Retrieve the player answer
-		answerIndex, answered := player.GetAnswer(question.Index)
-		// Check the player answer
-		correct := answered && question.IsCorrect(answerIndex)
-		// Send the player their marking result
-		player.Net.Send(net.AnswerResultPacket(correct))
-		if correct {
-			score := GetScore(player, question)
-			// Increase the player score
-			player.Score += score
-			if player.Score > 0 {
-				log.Printf("Player '%s' scored %d points", player.Name, score)
+		record := player.GetAnswer(question.Index)
+		if answerers != nil {
+			record = nil
+			if answerer := answerers[player.Team]; answerer == player {
+				record = player.GetAnswer(question.Index)
+			} else if answerer != nil { // Teammates are given their own copy of the team answer
+				record = player.SetTeamAnswer(answerer.GetAnswer(question.Index), answerer.Id)
 			}
 		}
+		game.MarkAnswer(player, record, question)
 	})
-	// Create a new scores packet
-	scorePacket := net.ScoresPacket(game.Players.CollectScores())
-	// Broadcast the scores' packet to everyone
-	game.Broadcast(scorePacket, true)
+	// Broadcast the scores to everyone
+	game.BroadcastScores()
 	// Set the question as marked
 	question.Marked = true
 }
 
+// MarkAnswer marks the provided answer record of the player against the
+// question, sending the player the result and awarding them the points if
+// the answer is correct. The record is nil if the player didn't answer
+func (game *Game) MarkAnswer(player *Player, record *AnswerRecord, question *ActiveQuestion) {
+	// Check the player answer
+	correct := record != nil && question.IsCorrect(record.Answer)
+	// Send the player their marking result
+	player.Send(net.AnswerResultPacket(correct, question.Question.Explanation))
+	if correct {
+		score := GetScore(record)
+		player.AnswersLock.Lock() // Results can be collected while self-paced players are marked
+		// Store the points awarded for this answer
+		record.Points = score
+		// Increase the player score
+		player.Score += score
+		player.AnswersLock.Unlock()
+		if score > 0 {
+			game.Log("player_score").WithPlayer(player.Id).With("points", score).Debug("Player '%s' scored %d points", player.Name, score)
+		}
+	}
+}
+
+// IsAnswerable checks whether the player can still answer their current
+// question. Answers are closed once the question is marked or its time is up
+func (game *Game) IsAnswerable(player *Player) bool {
+	q := game.QuestionFor(player) // Self-paced questions are replaced once marked so q can't change
+	return q != nil && !q.Marked && Time()-q.StartTime < QuestionTime
+}
+
 // NextQuestion moves on to the next question and informs all the clients
 // what the current question is
 func (game *Game) NextQuestion() {
@@ -332,19 +602,37 @@ func (game *Game) NextQuestion() {
 			Marked:    false,
 		}
 		// Broadcast the question
-		game.Broadcast(net.QuestionPacket(q), false)
+		game.BroadcastQuestion(game.ActiveQuestion)
+		game.SendHostQuestion() // Give the host the question with their notes
+		game.SendAnswerCount()  // Reset the answer count for the new question
+	}
+}
+
+// SendHostQuestion sends the host the active question along with the private
+// host notes. Co-hosts aren't sent the notes
+func (game *Game) SendHostQuestion() {
+	if q := game.ActiveQuestion; q != nil && game.State == Started && !q.Marked {
+		game.SendOwner(net.HostQuestionPacket(*q.Question))
 	}
 }
 
 // GameOver called when the game has ended and there is no more questions
-// sets the game state to stopped and logs the game over
+// sets the game state to stopped, logs the game over and stores the results
+// so that the host can download them
 func (game *Game) GameOver() {
 	game.SetState(Stopped)
-	log.Printf("Game over for game '%s' (%s)", game.Title, game.Id)
+	game.Log("game_over").Info("Game over for '%s'", game.Title)
+
+	var token Identifier
+	if game.SelfPaced { // The host of a self-paced game already has the results token
+		token = game.ResultsToken
+		StoreResultsAs(token, game.CollectResults())
+	} else {
+		token = StoreResults(game.CollectResults()) // Store the results of the game
+	}
+	game.SendHost(net.ResultsPacket(token)) // Tell the host how to download the results
 
-	GamesLock.Lock()       // Establish write lock on the games map
-	delete(Games, game.Id) // Remove the game
-	GamesLock.Unlock()     // Release write lock
+	game.Remove() // Remove the game from the games map
 }
 
 // SetState sets the current game state and broadcasts the game state packet
@@ -356,6 +644,12 @@ func (game *Game) SetState(state State) {
 
 // RemovePlayer Deletes the player from the players list. Made thread safe with PLock
 func (game *Game) RemovePlayer(player *Player) {
+	if game.RemovePending(player.Id) { // Players waiting for approval aren't in the game yet
+		return
+	}
+	if game.Players.Get(player.Id) != player { // Players that were kicked or promoted have already been removed
+		return
+	}
 	if game.State != Stopped { // If the game is stopped we don't need to inform the other players
 
 		// Create a remove player data packet
@@ -365,26 +659,80 @@ func (game *Game) RemovePlayer(player *Player) {
 	}
 	// Remove the player from the player list
 	game.Players.Remove(player.Id)
+	if game.State != Waiting { // Keep the answers of players that leave part way for the results
+		game.LeftLock.Lock()
+		game.Left = append(game.Left, player)
+		game.LeftLock.Unlock()
+	}
 	// Log a debug message saying who was disconnected
-	log.Printf("Player '%s' (%s) removed from game '%s' (%s)", player.Name, player.Id, game.Title, game.Id)
+	game.Log("player_remove").WithPlayer(player.Id).Info("Player '%s' removed from '%s'", player.Name, game.Title)
+}
+
+// Remove Removes the game from the games map and records how long the
+// game existed for if it hadn't already been removed
+func (game *Game) Remove() {
+	GamesLock.Lock() // Establish write lock on the games map
+	existing, contains := Games[game.Id]
+	removed := contains && existing == game
+	if removed { // Only remove the game if it hasn't already been removed
+		delete(Games, game.Id) // Remove the game
+		metrics.GameDuration.Observe(time.Since(game.CreatedTime).Seconds())
+	}
+	GamesLock.Unlock() // Release write lock
+	if removed {
+		// Wait for any snapshot being saved to finish before removing it
+		game.SnapshotLock.Lock()
+		DeleteSnapshot(game.Id) // Remove the snapshot of the game if there is one
+		game.SnapshotLock.Unlock()
+	}
+}
+
+// Rename Changes the name of the player and tells everyone in the game about
+// the new name. The name must already have been checked against the name policy
+func (game *Game) Rename(player *Player, name string) {
+	old := player.Name
+	player.Name = name
+	game.Broadcast(net.PlayerDataPacket(player.Id, name, net.RenameMode), true)
+	game.Log("player_rename").WithPlayer(player.Id).Info("Player '%s' renamed to '%s'", old, name)
+}
+
+// Kick Removes the player from the game and sends them a disconnect packet
+// with the provided reason
+func (game *Game) Kick(player *Player, reason string) {
+	game.RemovePlayer(player)                 // Remove the player from the game
+	player.Send(net.DisconnectPacket(reason)) // Send a disconnect packet to the player
 }
 
 // Stop Sets the game state to Stopped and calls RemovePlayer
 // on all the players. Made thread safe with PLock
 func (game *Game) Stop() {
+	game.StopWithReason("Removed from game")
+}
+
+// Close Stops the game and disconnects both the players and the
+// host using the provided reason
+func (game *Game) Close(reason string) {
+	game.StopWithReason(reason)
+	game.SendHost(net.DisconnectPacket(reason)) // Inform the host the game was stopped
+}
+
+// StopWithReason Sets the game state to Stopped and removes all the
+// players sending them a disconnect packet with the provided reason
+func (game *Game) StopWithReason(reason string) {
 	game.State = Stopped // Set the game state to stopped
-	packet := net.DisconnectPacket("Removed from game")
+	packet := net.DisconnectPacket(reason)
 	// Write safe iteration over all the players
 	game.Players.ForEachSafe(func(player *Player) {
 		// Remove the player
 		game.RemovePlayer(player)
 		// Send a disconnect packet to the player
-		player.Net.Send(packet)
+		player.Send(packet)
 	})
+	game.DisconnectSpectators(reason)
+	game.DisconnectCoHosts(reason)
+	game.SendPresenter(packet) // Disconnect the presenter display
 	// Log a debug messaging saying the game was stopped
-	log.Printf("Stopping game '%s' (%s)", game.Title, game.Id)
+	game.Log("game_stop").Info("Stopping game '%s'", game.Title)
 
-	GamesLock.Lock()       // Establish write lock on the games map
-	delete(Games, game.Id) // Remove the game
-	GamesLock.Unlock()     // Release write lock
+	game.Remove() // Remove the game from the games map
 }
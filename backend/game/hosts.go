@@ -0,0 +1,298 @@
+package game
+
+import (
+	"backend/net"
+	. "backend/tools"
+	"errors"
+	"fmt"
+	. "github.com/jacobtread/gowsps"
+	"time"
+)
+
+// Permission A control permission that the host can give to co-hosts
+type Permission = string
+
+const (
+	StartPermission Permission = "start" // Starting the game
+	SkipPermission  Permission = "skip"  // Skipping the current question
+	KickPermission  Permission = "kick"  // Kicking players from the game
+	PausePermission Permission = "pause" // Pausing and unpausing the game
+)
+
+// ControlPermissions The permissions that co-hosts are given when the host
+// doesn't choose any
+var ControlPermissions = []Permission{StartPermission, SkipPermission, KickPermission, PausePermission}
+
+// MaxCoHosts The maximum number of co-hosts a game can have
+const MaxCoHosts = 5
+
+// CoHost A structure representing a player that the host has promoted to help
+// control the game. Co-hosts are no longer players and are sent everything
+// that is sent to the host
+type CoHost struct {
+	Id          Identifier   // The id the co-host had as a player
+	Name        string       // The name the co-host had as a player
+	Address     string       // The remote address of the co-host
+	Permissions []Permission // The control permissions the co-host has
+	Promoted    time.Time    // The time the co-host was promoted
+}
+
+// CheckPermissions checks that the provided permissions are known control
+// permissions. No permissions gives all the ControlPermissions
+func CheckPermissions(permissions []string) ([]Permission, error) {
+	if len(permissions) == 0 {
+		return ControlPermissions, nil
+	}
+	for _, permission := range permissions { // Iterate over the requested permissions
+		known := false
+		for _, control := range ControlPermissions {
+			if permission == control {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown permission '%s'", permission)
+		}
+	}
+	return permissions, nil
+}
+
+// IsHost checks whether the connection is the host (owner) of the game
+func (game *Game) IsHost(conn *Connection) bool {
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	return conn != nil && game.Host == conn
+}
+
+// HasHost checks whether the host is connected to the game
+func (game *Game) HasHost() bool {
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	return game.Host != nil
+}
+
+// HasMember checks whether the connection is still the host or a co-host of
+// the game or the player is still in the game or waiting to be approved.
+// Players that were kicked or denied aren't members anymore
+func (game *Game) HasMember(conn *Connection, player *Player) bool {
+	if player != nil && (game.Players.Get(player.Id) == player || game.Players.GetPending(player.Id) == player) {
+		return true
+	}
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	return conn != nil && (game.Host == conn || game.CoHosts[conn] != nil)
+}
+
+// Can checks whether the connection is allowed to control the game with the
+// provided permission. The host can do everything and co-hosts can only do
+// what they were given permission to
+func (game *Game) Can(conn *Connection, permission Permission) bool {
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	if conn == nil {
+		return false
+	}
+	if game.Host == conn {
+		return true
+	}
+	if coHost, exists := game.CoHosts[conn]; exists {
+		for _, granted := range coHost.Permissions {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findCoHost retrieves the connection and co-host with the provided id. The
+// hosts lock must be held by the caller
+func (game *Game) findCoHost(id Identifier) (*Connection, *CoHost) {
+	for conn, coHost := range game.CoHosts {
+		if coHost.Id == id {
+			return conn, coHost
+		}
+	}
+	return nil, nil
+}
+
+// Promote removes the player from the game and makes them a co-host with the
+// provided permissions. Returns an error describing why they couldn't be
+// promoted
+func (game *Game) Promote(player *Player, permissions []Permission) error {
+	if player.Net == nil { // Players restored from snapshots may not be connected
+		return errors.New("That player isn't connected")
+	}
+	conn := player.Net
+	game.HostsLock.Lock()
+	if len(game.CoHosts) >= MaxCoHosts {
+		game.HostsLock.Unlock()
+		return fmt.Errorf("Games can't have more than %d co-hosts", MaxCoHosts)
+	}
+	if game.CoHosts == nil {
+		game.CoHosts = map[*Connection]*CoHost{}
+	}
+	coHost := &CoHost{
+		Id:          player.Id,
+		Name:        player.Name,
+		Address:     player.Address,
+		Permissions: permissions,
+		Promoted:    time.Now(),
+	}
+	game.CoHosts[conn] = coHost
+	game.HostsLock.Unlock()
+
+	game.RemovePlayer(player) // Co-hosts aren't players
+	send := func(packet Packet) { net.Send(conn, packet) }
+	send(net.HostRolePacket(false, permissions))
+	game.SendOverview(send)
+	game.SendCoHosts(send)
+	game.SendBans(send)
+	game.SendSpectatorCount(send)
+	game.SendHostExcept(net.CoHostPacket(coHost.Id, coHost.Name, net.AddMode), conn)
+	game.Log("cohost_promote").WithPlayer(player.Id).Info("Player '%s' promoted to co-host of '%s'", player.Name, game.Title)
+	return nil
+}
+
+// Demote removes the co-host with the provided id and disconnects them.
+// Returns false if there is no co-host with that id
+func (game *Game) Demote(id Identifier) bool {
+	game.HostsLock.Lock()
+	conn, coHost := game.findCoHost(id)
+	if coHost != nil {
+		delete(game.CoHosts, conn)
+	}
+	game.HostsLock.Unlock()
+	if coHost == nil {
+		return false
+	}
+	net.Send(conn, net.DisconnectPacket("The host removed you as a co-host"))
+	game.SendHost(net.CoHostPacket(coHost.Id, coHost.Name, net.RemoveMode))
+	game.Log("cohost_demote").With("cohost", id).Info("Co-host '%s' removed from '%s'", coHost.Name, game.Title)
+	return true
+}
+
+// TransferHost makes the co-host with the provided id the host of the game.
+// The previous host becomes a co-host with all the control permissions if
+// keep is true. Returns an error if there is no co-host with that id
+func (game *Game) TransferHost(id Identifier, keep bool) error {
+	game.HostsLock.Lock()
+	conn, coHost := game.findCoHost(id)
+	if coHost == nil {
+		game.HostsLock.Unlock()
+		return errors.New("That co-host isn't in the game")
+	}
+	delete(game.CoHosts, conn)
+	previous := game.Host
+	var demoted *CoHost
+	if keep && previous != nil { // Keep the previous host around as a co-host
+		demoted = &CoHost{
+			Id:          game.Players.CreatePlayerId(),
+			Name:        "Host",
+			Address:     game.HostAddress,
+			Permissions: ControlPermissions,
+			Promoted:    time.Now(),
+		}
+		game.CoHosts[previous] = demoted
+	}
+	game.Host = conn
+	game.HostAddress = coHost.Address
+	game.HostToken = CreateRandomId(16) // The previous host can no longer resume as the host
+	game.HostsLock.Unlock()
+
+	// Give the new host everything they need to take over
+	send := func(packet Packet) { net.Send(conn, packet) }
+	send(net.JoinGamePacket(true, game.Id, game.Title, game.HostToken))
+	send(net.HostRolePacket(true, ControlPermissions))
+	send(net.PresenterTokenPacket(game.PresenterToken))
+	for _, player := range game.Players.GetPendingArray() { // Ask the new host to approve any pending players
+		send(net.PendingPlayerPacket(player.Id, player.Name, net.AddMode))
+	}
+	game.SendCoHosts(send)
+	game.SendBans(send)
+	game.SendSpectatorCount(send)
+	game.SendAssignment(send, true)
+	game.SendHostQuestion()
+	// Tell the other co-hosts about the change
+	game.SendHostExcept(net.CoHostPacket(coHost.Id, coHost.Name, net.RemoveMode), conn, previous)
+	if demoted != nil {
+		sendPrevious := func(packet Packet) { net.Send(previous, packet) }
+		sendPrevious(net.HostRolePacket(false, demoted.Permissions))
+		sendPrevious(net.CoHostPacket(coHost.Id, coHost.Name, net.RemoveMode))
+		game.SendCoHosts(sendPrevious)
+		game.SendHostExcept(net.CoHostPacket(demoted.Id, demoted.Name, net.AddMode), conn, previous)
+	}
+	game.Log("host_transfer").With("cohost", id).Info("Host of '%s' transferred to '%s'", game.Title, coHost.Name)
+	return nil
+}
+
+// LeaveHost handles the connection leaving the game if it is the host or a
+// co-host. When the host leaves the co-host that was promoted first takes
+// over and the game is only stopped if there are no co-hosts. Returns false
+// if the connection isn't a host or co-host
+func (game *Game) LeaveHost(conn *Connection) bool {
+	game.HostsLock.Lock()
+	if conn == nil || (game.Host != conn && game.CoHosts[conn] == nil) {
+		game.HostsLock.Unlock()
+		return false
+	}
+	if coHost, exists := game.CoHosts[conn]; exists { // If a co-host is leaving
+		delete(game.CoHosts, conn)
+		game.HostsLock.Unlock()
+		game.SendHost(net.CoHostPacket(coHost.Id, coHost.Name, net.RemoveMode))
+		game.Log("cohost_leave").With("cohost", coHost.Id).Info("Co-host '%s' left '%s'", coHost.Name, game.Title)
+		return true
+	}
+	var next *CoHost
+	for _, coHost := range game.CoHosts { // Find the co-host that was promoted first
+		if next == nil || coHost.Promoted.Before(next.Promoted) {
+			next = coHost
+		}
+	}
+	game.HostsLock.Unlock()
+	if next == nil && game.SelfPaced { // Self-paced games carry on without a host until they close
+		game.HostsLock.Lock()
+		game.Host = nil
+		game.HostsLock.Unlock()
+		game.Log("host_leave").Info("Host left self-paced game '%s'", game.Title)
+	} else if next == nil { // Nobody to take over so the game has to stop
+		game.Stop()
+	} else if err := game.TransferHost(next.Id, false); err != nil { // The co-host left at the same time
+		game.Stop()
+	}
+	return true
+}
+
+// SendOwner sends the provided packet to the host (owner) of the game only.
+// Used for packets that co-hosts shouldn't receive
+func (game *Game) SendOwner(packet Packet) {
+	game.HostsLock.RLock()
+	host := game.Host
+	game.HostsLock.RUnlock()
+	if host != nil { // Self-paced games may not have a host
+		net.Send(host, packet)
+	}
+}
+
+// SendCoHosts uses the provided send function to send the co-hosts of the game
+func (game *Game) SendCoHosts(send func(packet Packet)) {
+	game.HostsLock.RLock()
+	defer game.HostsLock.RUnlock()
+	for _, coHost := range game.CoHosts {
+		send(net.CoHostPacket(coHost.Id, coHost.Name, net.AddMode))
+	}
+}
+
+// DisconnectCoHosts sends all the co-hosts a disconnect packet with the
+// provided reason and removes them from the game
+func (game *Game) DisconnectCoHosts(reason string) {
+	game.HostsLock.Lock()
+	coHosts := game.CoHosts
+	game.CoHosts = nil
+	game.HostsLock.Unlock()
+	packet := net.DisconnectPacket(reason)
+	for conn := range coHosts {
+		net.Send(conn, packet)
+	}
+}
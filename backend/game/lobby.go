@@ -0,0 +1,81 @@
+package game
+
+import (
+	"backend/net"
+	. "backend/tools"
+	"errors"
+	. "github.com/jacobtread/gowsps"
+)
+
+// RequestJoin adds a new player to the pending players of the game and asks
+// the host to approve them. Used instead of Join when the game is in approval
+// mode. Returns a reference to the pending player
+func (game *Game) RequestJoin(conn *Connection, address string, name string, team int) *Player {
+	player := game.Players.AddPending(conn, address, name) // Create the pending player
+	player.Team = team                                     // Keep the team the player picked until they are approved
+	// Tell the player they are waiting to be approved
+	player.Send(net.PendingPlayerPacket(player.Id, player.Name, net.SelfMode))
+	// Ask the host to approve the player
+	game.SendHost(net.PendingPlayerPacket(player.Id, player.Name, net.AddMode))
+	game.Log("player_pending").WithPlayer(player.Id).Info("Player '%s' is waiting to join '%s'", name, game.Title)
+	return player
+}
+
+// Approve moves the pending player with the provided id into the game.
+// Returns an error describing why the player couldn't be approved
+func (game *Game) Approve(id Identifier) error {
+	if game.Players.Count() >= MaxPlayers { // Check the game has room before taking the player
+		return errors.New("The game is full")
+	}
+	player := game.Players.TakePending(id)
+	if player == nil { // If the player isn't waiting to join
+		return errors.New("That player isn't waiting to join")
+	}
+	game.SendHost(net.PendingPlayerPacket(player.Id, player.Name, net.RemoveMode))
+	game.Admit(player) // Add the player to the game
+	return nil
+}
+
+// ApproveAll approves all the pending players. Players that don't fit in the
+// game are denied
+func (game *Game) ApproveAll() {
+	for _, player := range game.Players.GetPendingArray() { // Iterate over the pending players
+		if err := game.Approve(player.Id); err != nil {
+			game.Deny(player.Id, "That game is full")
+		}
+	}
+}
+
+// Deny removes the pending player with the provided id and sends them a
+// disconnect packet with the provided reason. Returns false if the player
+// wasn't waiting to join
+func (game *Game) Deny(id Identifier, reason string) bool {
+	player := game.Players.TakePending(id)
+	if player == nil { // If the player isn't waiting to join
+		return false
+	}
+	game.SendHost(net.PendingPlayerPacket(player.Id, player.Name, net.RemoveMode))
+	player.Send(net.DisconnectPacket(reason))
+	game.Log("player_denied").WithPlayer(player.Id).Info("Player '%s' was denied from '%s'", player.Name, game.Title)
+	return true
+}
+
+// DenyAll denies all the pending players with the provided reason
+func (game *Game) DenyAll(reason string) {
+	for _, player := range game.Players.GetPendingArray() { // Iterate over the pending players
+		game.Deny(player.Id, reason)
+	}
+}
+
+// RemovePending removes the pending player with the provided id without
+// sending them anything. Used when the player leaves before being approved.
+// Returns false if the player wasn't waiting to join
+func (game *Game) RemovePending(id Identifier) bool {
+	player := game.Players.TakePending(id)
+	if player == nil { // If the player isn't waiting to join
+		return false
+	}
+	game.SendHost(net.PendingPlayerPacket(player.Id, player.Name, net.RemoveMode))
+	game.Log("player_pending_leave").WithPlayer(player.Id).Info("Player '%s' stopped waiting to join '%s'", player.Name, game.Title)
+	return true
+}
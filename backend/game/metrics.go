@@ -0,0 +1,38 @@
+package game
+
+import (
+	"backend/metrics"
+	"strconv"
+)
+
+// Metrics which are collected from the games when the metrics are requested
+var (
+	ActiveGamesMetric = metrics.NewGaugeFunc("quizler_games_active", "Number of games currently in progress", "", func() map[string]float64 {
+		return map[string]float64{"": float64(len(All()))}
+	})
+	// Players aren't labelled by game as the metrics would expose the codes of every game
+	PlayersMetric = metrics.NewGaugeFunc("quizler_players", "Number of players in all the games currently in progress", "", func() map[string]float64 {
+		total := 0
+		for _, game := range All() { // Iterate over the games
+			total += game.Players.Count()
+		}
+		return map[string]float64{"": float64(total)}
+	})
+	// The number of games with at most each number of players like the buckets of a histogram
+	GamePlayersMetric = metrics.NewGaugeFunc("quizler_games_by_players", "Number of games currently in progress with at most le players", "le", func() map[string]float64 {
+		counts := map[string]float64{"+Inf": 0}
+		for _, bound := range metrics.PlayerBuckets {
+			counts[strconv.FormatFloat(bound, 'f', -1, 64)] = 0
+		}
+		for _, game := range All() { // Iterate over the games
+			players := float64(game.Players.Count())
+			for _, bound := range metrics.PlayerBuckets {
+				if players <= bound {
+					counts[strconv.FormatFloat(bound, 'f', -1, 64)]++
+				}
+			}
+			counts["+Inf"]++
+		}
+		return counts
+	})
+)
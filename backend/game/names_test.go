@@ -0,0 +1,65 @@
+package game
+
+import "testing"
+
+// newNamesGame creates a game with a player called José (NFC) and a banned
+// player called Admin for testing the name checks
+func newNamesGame() *Game {
+	game := &Game{Players: NewPlayerStore()}
+	game.Players.Add(game.Players.NewPlayer(nil, "127.0.0.1:1", "Jos\u00e9"))
+	game.Bans = map[string]*Ban{"B1": {Id: "B1", Name: "Admin", IP: "10.0.0.1"}}
+	return game
+}
+
+func TestJoinNameTaken(t *testing.T) {
+	game := newNamesGame()
+	tests := []struct {
+		name  string
+		taken bool
+	}{
+		{"Jos\u00e9", true},
+		{"Jose\u0301", true},
+		{"JOSE", true},
+		{"jose", true},
+		{"Josh", false},
+	}
+	for _, test := range tests {
+		if got := game.IsNameTaken(test.name); got != test.taken {
+			t.Errorf("IsNameTaken(%q) = %v, want %v", test.name, got, test.taken)
+		}
+	}
+}
+
+func TestRenameNameTaken(t *testing.T) {
+	game := newNamesGame()
+	jose := game.Players.GetPlayerArray()[0]
+	other := game.Players.NewPlayer(nil, "127.0.0.1:2", "Bob")
+	game.Players.Add(other)
+	if game.IsNameTakenExcept("Jose\u0301", jose.Id) {
+		t.Error("a player renaming to their own name in another form shouldn't be taken")
+	}
+	if !game.IsNameTakenExcept("Jose\u0301", other.Id) {
+		t.Error("renaming to a name that looks like another player should be taken")
+	}
+}
+
+func TestBannedName(t *testing.T) {
+	game := newNamesGame()
+	tests := []struct {
+		ip     string
+		name   string
+		banned bool
+	}{
+		{"10.0.0.2", "Admin", true},
+		{"10.0.0.2", "ADMIN", true},
+		{"10.0.0.2", "Аdmin", true}, // Cyrillic А
+		{"10.0.0.2", "Admın", true}, // Dotless i
+		{"10.0.0.1", "Bob", false},  // Not banned by IP
+		{"10.0.0.2", "", false},
+	}
+	for _, test := range tests {
+		if got := game.IsBanned(test.ip, test.name); got != test.banned {
+			t.Errorf("IsBanned(%q, %q) = %v, want %v", test.ip, test.name, got, test.banned)
+		}
+	}
+}
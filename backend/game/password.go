@@ -0,0 +1,52 @@
+package game
+
+import (
+	"backend/net"
+	"crypto/subtle"
+)
+
+// MaxPasswordLength The maximum number of characters in a game password
+const MaxPasswordLength = 64
+
+// HasPassword Checks whether the game requires a password to join
+func (game *Game) HasPassword() bool {
+	game.PasswordLock.RLock()
+	defer game.PasswordLock.RUnlock()
+	return game.Password != ""
+}
+
+// SetPassword Changes the password required to join the game. Players that
+// have already joined are unaffected. An empty password makes the game public
+func (game *Game) SetPassword(password string) {
+	game.PasswordLock.Lock()
+	game.Password = password
+	game.PasswordLock.Unlock()
+	game.Log("password_change").With("private", password != "").Info("Changed the game password")
+}
+
+// CheckPassword Checks whether the provided password matches the password for
+// the game. Always matches when the game doesn't have a password
+func (game *Game) CheckPassword(password string) bool {
+	game.PasswordLock.RLock()
+	defer game.PasswordLock.RUnlock()
+	if game.Password == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(game.Password)) == 1
+}
+
+// HiddenName The name the host is shown for failed joins where the name the
+// player gave isn't allowed
+const HiddenName = "Someone"
+
+// JoinFailed Records an attempt to join the game with the wrong password and
+// reports it to the host. The name must already have been checked against the
+// name policy
+func (game *Game) JoinFailed(name string, address string) {
+	game.PasswordLock.Lock()
+	game.FailedJoins++
+	attempts := game.FailedJoins
+	game.PasswordLock.Unlock()
+	game.Log("join_failed").With("address", address).With("name", name).Warn("Rejected join with the wrong password")
+	game.SendHost(net.JoinFailedPacket(name, attempts))
+}
@@ -1,6 +1,7 @@
 package game
 
 import (
+	"backend/metrics"
 	"backend/net"
 	. "backend/tools"
 	"github.com/jacobtread/gowsps"
@@ -11,12 +12,33 @@ import (
 type (
 	// Player A structure representing a player in the game
 	Player struct {
-		Net        *gowsps.Connection            // The connection to the player socket
-		Id         Identifier                    // The unique ID of this player
-		Name       string                        // The name of this player
-		Score      uint32                        // The score this player has
-		Answers    map[QuestionIndex]AnswerIndex // A map of the question index to the answer chosen
-		AnswerTime time.Duration                 // The time of which the player provided its answer
+		Net     *gowsps.Connection              // The connection to the player socket
+		Address string                          // The remote address of the player socket
+		Id      Identifier                      // The unique ID of this player
+		Token   Identifier                      // The session token used to resume this player
+		Name    string                          // The name of this player
+		Score   uint32                          // The score this player has
+		Team    int                             // The index of the team the player is in or NoTeam
+		Answers map[QuestionIndex]*AnswerRecord // A map of the question index to the answer record
+
+		Question *ActiveQuestion // The question the player is answering in self-paced games
+		Finished bool            // Whether the player has answered every question in a self-paced game
+		Seed     int64           // The seed for the order the answers are shown to the player in
+
+		// A lock for the answers, score and self-paced question of the player. The
+		// question is replaced rather than modified so it can be used after unlocking
+		AnswersLock sync.RWMutex
+	}
+
+	// AnswerRecord A structure representing a players answer to a single question
+	AnswerRecord struct {
+		Question QuestionIndex // The index of the question that was answered
+		Answer   AnswerIndex   // The index of the answer chosen
+		Time     time.Time     // The time at which the player answered
+		Latency  time.Duration // The time taken to answer from the start of the question
+		Points   uint32        // The points awarded for this answer once marked
+
+		AnsweredBy Identifier // The teammate that gave this answer for the team with consensus
 	}
 
 	// PlayerStore A structure for storing, retrieving, removing and overall
@@ -24,42 +46,132 @@ type (
 	PlayerStore struct {
 		Lock *sync.RWMutex          // A lock for ensuring that writes are synchronized
 		Map  map[Identifier]*Player // The underlying map that stores the players mapped to Identifier's
+
+		// Players waiting for the host to approve them in approval mode. These
+		// aren't part of the game until they are moved into the Map
+		Pending map[Identifier]*Player
 	}
 )
 
 // NewPlayerStore Creates a new player store
 func NewPlayerStore() PlayerStore {
 	return PlayerStore{
-		Lock: &sync.RWMutex{},
-		Map:  map[Identifier]*Player{},
+		Lock:    &sync.RWMutex{},
+		Map:     map[Identifier]*Player{},
+		Pending: map[Identifier]*Player{},
+	}
+}
+
+// Send sends the provided packet to the player if the player is connected
+func (player *Player) Send(packet gowsps.Packet) {
+	if player.Net != nil { // Players restored from snapshots may not be connected
+		net.Send(player.Net, packet)
+	}
+}
+
+// GetAnswer retrieves the player answer record for the provided question index
+// or nil if the player didn't answer that question
+func (player *Player) GetAnswer(index QuestionIndex) *AnswerRecord {
+	player.AnswersLock.RLock() // Establish a read lock on the answers map
+	record, exists := player.Answers[index]
+	player.AnswersLock.RUnlock() // Release the read lock
+	if !exists {
+		return nil
 	}
+	return record
+}
+
+// GetScore retrieves the score of the player
+func (player *Player) GetScore() uint32 {
+	player.AnswersLock.RLock() // Establish a read lock on the player score
+	defer player.AnswersLock.RUnlock()
+	return player.Score
+}
+
+// CurrentQuestion retrieves the question the player is answering in a
+// self-paced game or nil if they don't have one
+func (player *Player) CurrentQuestion() *ActiveQuestion {
+	player.AnswersLock.RLock() // Establish a read lock on the player question
+	defer player.AnswersLock.RUnlock()
+	return player.Question
+}
+
+// SetQuestion sets the question the player is answering in a self-paced game
+// and whether they have finished answering questions
+func (player *Player) SetQuestion(question *ActiveQuestion, finished bool) {
+	player.AnswersLock.Lock() // Establish write lock on the player question
+	player.Question = question
+	player.Finished = finished
+	player.AnswersLock.Unlock() // Release write lock
+}
+
+// HasFinished checks whether the player has answered every question in a
+// self-paced game
+func (player *Player) HasFinished() bool {
+	player.AnswersLock.RLock() // Establish a read lock on the player question
+	defer player.AnswersLock.RUnlock()
+	return player.Finished
 }
 
-// GetAnswer retrieves the player answer for the provided question index and
-// returns both the value and weather it exists or not
-func (player *Player) GetAnswer(index QuestionIndex) (AnswerIndex, bool) {
-	// Retrieve the value
-	answer, exists := player.Answers[index]
-	return answer, exists
+// SetTeamAnswer records a copy of the answer that a teammate gave for the team
+// as the player answer so that the player is marked and exported with it.
+// Returns the copy
+func (player *Player) SetTeamAnswer(record *AnswerRecord, by Identifier) *AnswerRecord {
+	copied := *record
+	copied.Points = 0
+	copied.AnsweredBy = by
+	player.AnswersLock.Lock() // Establish write lock on the answers map
+	player.Answers[record.Question] = &copied
+	player.AnswersLock.Unlock() // Release write lock
+	return &copied
 }
 
 // HasAnswered Checks whether the player has already answered the current question
 func (player *Player) HasAnswered(game *Game) bool {
-	q := game.ActiveQuestion               // Retrieve the active question from the game
-	_, contains := player.Answers[q.Index] // Retrieve the player answer
-	return contains
+	q := game.QuestionFor(player)                       // Retrieve the question the player is answering
+	return q != nil && player.GetAnswer(q.Index) != nil // Check for the player answer
+}
+
+// CopyAnswers creates a copy of the player answers map which is safe to use
+// while the player continues to answer questions and is marked
+func (player *Player) CopyAnswers() map[QuestionIndex]*AnswerRecord {
+	player.AnswersLock.RLock() // Establish a read lock on the answers map
+	defer player.AnswersLock.RUnlock()
+	out := make(map[QuestionIndex]*AnswerRecord, len(player.Answers))
+	for index, record := range player.Answers { // Copy each of the answer records
+		copied := *record
+		out[index] = &copied
+	}
+	return out
 }
 
 // Answer sets the player answer to the provided answer index for the current quest
 func (player *Player) Answer(game *Game, id AnswerIndex) {
-	player.AnswerTime = Time()                       // Set the time of answer
-	q := game.ActiveQuestion                         // Retrieve the active question from the game
-	max := len(game.ActiveQuestion.Question.Answers) // Get the maximum question index
-	if id >= max {                                   // If the provided answer is greater
+	t := Time()                    // Get the time of answer
+	q := game.QuestionFor(player)  // Retrieve the question the player is answering
+	max := len(q.Question.Answers) // Get the maximum question index
+	if id >= max {                 // If the provided answer is greater
 		id = max - 1 // Set the answer to the last answer
 	}
-	// Set the index of the answer in the player answers map
-	player.Answers[q.Index] = id
+	// Map the answer the player chose back to the canonical answer
+	id = game.CanonicalAnswer(player, q.Index, id)
+	// Set the answer record in the player answers map
+	player.AnswersLock.Lock()                   // Establish write lock on the answers map
+	if game.SelfPaced && player.Question != q { // The question was marked while answering
+		player.AnswersLock.Unlock()
+		return
+	}
+	player.Answers[q.Index] = &AnswerRecord{
+		Question: q.Index,
+		Answer:   id,
+		Time:     time.Unix(0, int64(t)),
+		Latency:  t - q.StartTime,
+	}
+	player.AnswersLock.Unlock() // Release write lock
+	// Record the time taken to answer in the latency metrics
+	metrics.AnswerLatency.Observe((t - q.StartTime).Seconds())
+	// Tell the host and presenter how many players have answered
+	game.SendAnswerCount()
 }
 
 // CreatePlayerId Creates a new unique player identifier. Safely establishes read
@@ -70,7 +182,8 @@ func (store *PlayerStore) CreatePlayerId() Identifier {
 	for {                      // Infinitely loop until a unique Identifier is found
 		id := CreateRandomId(6) // Create a random identifier
 		_, contains := store.Map[id]
-		if !contains { // Ensure the Identifier doesn't already exist
+		_, pending := store.Pending[id]
+		if !contains && !pending { // Ensure the Identifier doesn't already exist
 			return id // Return the identifier
 		}
 	}
@@ -79,26 +192,89 @@ func (store *PlayerStore) CreatePlayerId() Identifier {
 // Create a new player and add it to the PlayerStore. Sends the player
 // data of all other players in the game to that player and adds them to
 // player map. Returns a pointer to the created player
-func (store *PlayerStore) Create(conn *gowsps.Connection, name string) *Player {
-	id := store.CreatePlayerId() // Create a unique player ID
-	player := Player{
-		Net:     conn,                            // Set the net connection
-		Id:      id,                              // Set the unique id
-		Name:    name,                            // Set the name
-		Score:   0,                               // Initial score of zero
-		Answers: map[QuestionIndex]AnswerIndex{}, // Empty answers map
+func (store *PlayerStore) Create(conn *gowsps.Connection, address string, name string) *Player {
+	player := store.NewPlayer(conn, address, name) // Create the player
+	store.Add(player)                              // Add the player to the store
+	return player                                  // Return the player pointer
+}
+
+// NewPlayer Creates a new player with a unique id without adding it to the
+// PlayerStore
+func (store *PlayerStore) NewPlayer(conn *gowsps.Connection, address string, name string) *Player {
+	return &Player{
+		Net:     conn,                              // Set the net connection
+		Address: address,                           // Set the remote address
+		Id:      store.CreatePlayerId(),            // Create a unique player ID
+		Token:   CreateRandomId(16),                // Create a session token
+		Name:    name,                              // Set the name
+		Score:   0,                                 // Initial score of zero
+		Team:    NoTeam,                            // Not in a team until admitted
+		Answers: map[QuestionIndex]*AnswerRecord{}, // Empty answers map
+		Seed:    RandomSeed(),                      // Seed for shuffling the answers
 	}
+}
 
+// Add Adds the player to the player map sending the player the data of all
+// the other players in the game
+func (store *PlayerStore) Add(player *Player) {
 	// Iterate over all the players in the game
 	store.ForEach(func(otherId Identifier, other *Player) {
 		// Send the player the data for each other player in the game
-		player.Net.Send(net.PlayerDataPacket(otherId, other.Name, net.AddMode))
+		player.Send(net.PlayerDataPacket(otherId, other.Name, net.AddMode))
 	})
 
-	store.Lock.Lock()       // Establish write lock over the players map
-	store.Map[id] = &player // Set the identifier to the player pointer in the player map
-	store.Lock.Unlock()     // Release write lock
-	return &player          // Return the player pointer
+	store.Lock.Lock()             // Establish write lock over the players map
+	store.Map[player.Id] = player // Set the identifier to the player pointer in the player map
+	store.Lock.Unlock()           // Release write lock
+}
+
+// AddPending Creates a new player and adds it to the pending players where
+// it waits to be approved by the host. Returns a pointer to the created player
+func (store *PlayerStore) AddPending(conn *gowsps.Connection, address string, name string) *Player {
+	player := store.NewPlayer(conn, address, name) // Create the player
+	store.Lock.Lock()                              // Establish write lock over the players map
+	store.Pending[player.Id] = player              // Add the player to the pending players
+	store.Lock.Unlock()                            // Release write lock
+	return player
+}
+
+// TakePending Removes the pending player with the provided id returning the
+// player or nil if there is no pending player with that id
+func (store *PlayerStore) TakePending(id Identifier) *Player {
+	store.Lock.Lock()         // Establish write lock over the players map
+	defer store.Lock.Unlock() // Defer the releasing of the write lock
+	player, exists := store.Pending[id]
+	if !exists {
+		return nil
+	}
+	delete(store.Pending, id)
+	return player
+}
+
+// GetPending Retrieves the pending player with the provided id or nil if there
+// is no player with that id waiting to be approved
+func (store *PlayerStore) GetPending(id Identifier) *Player {
+	store.Lock.RLock()
+	defer store.Lock.RUnlock()
+	return store.Pending[id]
+}
+
+// GetPendingArray Creates a copy of the pending players as an array
+func (store *PlayerStore) GetPendingArray() []*Player {
+	store.Lock.RLock()
+	defer store.Lock.RUnlock()
+	players := make([]*Player, 0, len(store.Pending))
+	for _, player := range store.Pending {
+		players = append(players, player)
+	}
+	return players
+}
+
+// PendingCount Retrieves the number of players waiting to be approved
+func (store *PlayerStore) PendingCount() int {
+	store.Lock.RLock()
+	defer store.Lock.RUnlock()
+	return len(store.Pending)
 }
 
 // ForEach Runs the provided action on each player in the
@@ -177,6 +353,26 @@ func (store *PlayerStore) Get(id Identifier) *Player {
 	}
 }
 
+// GetByToken retrieves a pointer to the player with a matching session token
+// or nil if there are no players with that token
+func (store *PlayerStore) GetByToken(token Identifier) *Player {
+	store.Lock.RLock()                 // Establish a read lock on the players map
+	defer store.Lock.RUnlock()         // Defer the releasing of the read lock
+	for _, player := range store.Map { // Iterate over the players map
+		if player.Token == token {
+			return player
+		}
+	}
+	return nil
+}
+
+// Count Retrieves the number of players in the player map
+func (store *PlayerStore) Count() int {
+	store.Lock.RLock()         // Establish a read lock on the players map
+	defer store.Lock.RUnlock() // Defer the releasing of the read lock
+	return len(store.Map)
+}
+
 // Remove Safely removes the player with the provided Identifier from the players
 // map. This is concurrency safe because it uses locks
 func (store *PlayerStore) Remove(id Identifier) {
@@ -198,12 +394,28 @@ func (store *PlayerStore) RemoveEach(action func(player *Player)) {
 	store.Lock.Unlock() // Release write lock
 }
 
+// MinScore Retrieves the lowest score of all the players or zero if there
+// are no players
+func (store *PlayerStore) MinScore() uint32 {
+	store.Lock.RLock()         // Establish a read lock on the players map
+	defer store.Lock.RUnlock() // Defer the releasing of the read lock
+	var min uint32
+	first := true
+	for _, player := range store.Map { // Iterate over the players map
+		if score := player.GetScore(); first || score < min {
+			min = score
+			first = false
+		}
+	}
+	return min
+}
+
 // CollectScores collects all the player scores into a map of the player
 // Identifier to the score value. This is used for the score update packet
 func (store *PlayerStore) CollectScores() ScoreMap {
 	out := ScoreMap{}                                   // The store to return
 	store.ForEach(func(id Identifier, player *Player) { // Iterate over the players
-		out[id] = player.Score // Assign all the score values
+		out[id] = player.GetScore() // Assign all the score values
 	})
 	return out
 }
@@ -0,0 +1,65 @@
+package game
+
+import (
+	. "backend/tools"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// CheckPools checks that each of the pools to draw questions from exists in the
+// questions and has enough questions for the number that are drawn from it
+func CheckPools(questions []QuestionData, pools map[string]int) error {
+	sizes := map[string]int{}
+	for _, question := range questions { // Count the questions in each pool
+		if question.Pool != "" {
+			sizes[question.Pool]++
+		}
+	}
+	for pool, count := range pools { // Check each of the pools being drawn from
+		size, exists := sizes[pool]
+		if !exists {
+			return fmt.Errorf("There aren't any questions in the pool '%s'", pool)
+		}
+		if count < 1 || count > size {
+			return fmt.Errorf("Between 1 and %d questions can be drawn from the pool '%s'", size, pool)
+		}
+	}
+	return nil
+}
+
+// DrawQuestions draws the provided number of questions at random from each of
+// the pools. Questions that aren't in one of the pools are always played. The
+// drawn questions keep the order they have in the quiz and the same seed will
+// always draw the same questions
+func DrawQuestions(questions []QuestionData, pools map[string]int, seed int64) []QuestionData {
+	if len(pools) == 0 { // Play all the questions when there are no pools
+		return questions
+	}
+	r := rand.New(rand.NewSource(seed))
+	indexes := map[string][]int{}
+	for i, question := range questions { // Group the question indexes by pool
+		if _, drawn := pools[question.Pool]; drawn {
+			indexes[question.Pool] = append(indexes[question.Pool], i)
+		}
+	}
+	names := make([]string, 0, len(pools))
+	for pool := range pools {
+		names = append(names, pool)
+	}
+	sort.Strings(names) // Sorted so that the same seed always draws the same questions
+	picked := make([]bool, len(questions))
+	for _, pool := range names {
+		in := indexes[pool]
+		for _, n := range r.Perm(len(in))[:pools[pool]] { // Pick the questions from the pool
+			picked[in[n]] = true
+		}
+	}
+	out := make([]QuestionData, 0, len(questions))
+	for i, question := range questions {
+		if _, drawn := pools[question.Pool]; !drawn || picked[i] {
+			out = append(out, question)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package game
+
+import (
+	"backend/net"
+	. "github.com/jacobtread/gowsps"
+)
+
+// AttachPresenter attaches the connection to the game as the presenter
+// display. The presenter receives everything that is broadcast including the
+// questions along with the answer counts which the host uses to drive a
+// separate screen. Any previous presenter is sent a disconnect packet and
+// stops receiving packets, IsPresenter no longer reports it as attached
+func (game *Game) AttachPresenter(conn *Connection) {
+	game.PresenterLock.Lock()
+	previous := game.Presenter
+	game.Presenter = conn
+	game.PresenterLock.Unlock()
+	if previous != nil && previous != conn { // Only one presenter can be attached
+		net.Send(previous, net.DisconnectPacket("Another presenter display was attached"))
+	}
+
+	send := func(packet Packet) { net.Send(conn, packet) }
+	send(net.SpectatingPacket(game.Id, game.Title, true))
+	game.SendOverview(send)
+	game.Log("presenter_attach").Info("Presenter attached to '%s'", game.Title)
+}
+
+// DetachPresenter detaches the connection from the game if it is the
+// current presenter
+func (game *Game) DetachPresenter(conn *Connection) {
+	game.PresenterLock.Lock()
+	detached := game.Presenter == conn
+	if detached {
+		game.Presenter = nil
+	}
+	game.PresenterLock.Unlock()
+	if detached {
+		game.Log("presenter_detach").Info("Presenter detached from '%s'", game.Title)
+	}
+}
+
+// IsPresenter checks whether the connection is the presenter attached to the
+// game. Presenters that were replaced by another display aren't attached
+func (game *Game) IsPresenter(conn *Connection) bool {
+	game.PresenterLock.RLock()
+	defer game.PresenterLock.RUnlock()
+	return conn != nil && game.Presenter == conn
+}
+
+// SendPresenter sends the provided packet to the presenter if one is attached
+func (game *Game) SendPresenter(packet Packet) {
+	game.PresenterLock.RLock()
+	defer game.PresenterLock.RUnlock()
+	if game.Presenter != nil {
+		net.Send(game.Presenter, packet)
+	}
+}
+
+// SendAnswerCount sends the number of players that have answered the active
+// question to the host and the presenter
+func (game *Game) SendAnswerCount() {
+	q := game.ActiveQuestion
+	if q == nil { // Nothing to count without a question
+		return
+	}
+	var teamRecords map[int]*AnswerRecord
+	if game.TeamConsensus { // Players count as answered once their team has answered
+		teamRecords = game.TeamRecords(q.Index)
+	}
+	answered, total := 0, 0
+	game.Players.ForEach(func(id string, player *Player) {
+		total++
+		if _, teamAnswered := teamRecords[player.Team]; teamAnswered || player.GetAnswer(q.Index) != nil {
+			answered++
+		}
+	})
+	packet := net.AnswerCountPacket(answered, total)
+	game.SendHost(packet)
+	game.SendPresenter(packet)
+}
@@ -0,0 +1,251 @@
+package game
+
+import (
+	. "backend/tools"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResultsLifetime The amount of time that the results of a finished game are
+// kept in memory for the host to download them
+var ResultsLifetime = time.Hour
+
+type (
+	// Results A structure representing the final results of a game which
+	// can be exported as either CSV or JSON
+	Results struct {
+		Id        Identifier       `json:"id"`              // The game code of the game
+		Title     string           `json:"title"`           // The title of the game
+		Questions []QuestionResult `json:"questions"`       // The questions that were played
+		Players   []PlayerResult   `json:"players"`         // The results for each player
+		Teams     []TeamResult     `json:"teams,omitempty"` // The results for each team in team games
+		EndTime   time.Time        `json:"end_time"`        // The time that the game ended
+		Seed      int64            `json:"seed"`            // The seed the questions were drawn and shuffled with
+	}
+
+	// QuestionResult A structure representing a question within the results
+	QuestionResult struct {
+		Question string        `json:"question"`       // The contents of the question
+		Answers  []string      `json:"answers"`        // The possible answer values
+		Values   []AnswerIndex `json:"values"`         // The indexes of the correct answers
+		Pool     string        `json:"pool,omitempty"` // The pool the question was drawn from
+
+		Explanation string `json:"explanation,omitempty"` // The explanation shown when the question was marked
+	}
+
+	// PlayerResult A structure representing the results for a single player
+	PlayerResult struct {
+		Id      Identifier     `json:"id"`             // The id of the player
+		Name    string         `json:"name"`           // The name of the player
+		Team    string         `json:"team,omitempty"` // The name of the team the player was in
+		Left    bool           `json:"left,omitempty"` // Whether the player left before the game ended
+		Score   uint32         `json:"score"`          // The final score of the player
+		Answers []AnswerResult `json:"answers"`        // The answers for each question
+	}
+
+	// TeamResult A structure representing the results for a single team
+	TeamResult struct {
+		Name  string `json:"name"`  // The name of the team
+		Score uint32 `json:"score"` // The final score of the team
+	}
+
+	// AnswerResult A structure representing a players answer to a single question
+	AnswerResult struct {
+		Question   QuestionIndex `json:"question"`              // The index of the question
+		Answered   bool          `json:"answered"`              // Whether the player answered
+		Answer     AnswerIndex   `json:"answer"`                // The index of the chosen answer
+		Correct    bool          `json:"correct"`               // Whether the chosen answer was correct
+		Points     uint32        `json:"points"`                // The points awarded for this question
+		Time       int64         `json:"time"`                  // The time taken to answer in ms
+		AnsweredAt *time.Time    `json:"answered_at,omitempty"` // The time at which the player answered
+		AnsweredBy Identifier    `json:"answered_by,omitempty"` // The teammate that answered for the team with consensus
+	}
+)
+
+// ResultsLock A lock for modifying the stored results map
+var ResultsLock = sync.RWMutex{}
+
+// StoredResults A map of download tokens to the results of finished games
+var StoredResults = map[Identifier]*Results{}
+
+// CollectResults collects the results of the game from the answers of all
+// the players currently in the game and the players that left part way
+func (game *Game) CollectResults() *Results {
+	results := Results{
+		Id:        game.Id,
+		Title:     game.Title,
+		Questions: make([]QuestionResult, len(game.Questions)),
+		EndTime:   time.Now(),
+		Seed:      game.Seed,
+	}
+	for i, question := range game.Questions { // Iterate over the game questions
+		results.Questions[i] = QuestionResult{
+			Question:    question.Question,
+			Answers:     question.Answers,
+			Values:      question.Values,
+			Pool:        question.Pool,
+			Explanation: question.Explanation,
+		}
+	}
+	addPlayer := func(player *Player, left bool) {
+		answers := player.CopyAnswers() // Self-paced players can be marked while collecting
+		playerResult := PlayerResult{
+			Id:      player.Id,
+			Name:    player.Name,
+			Score:   player.GetScore(),
+			Left:    left,
+			Answers: make([]AnswerResult, len(game.Questions)),
+		}
+		if game.IsTeam(player.Team) {
+			playerResult.Team = game.Teams[player.Team]
+		}
+		for i := range game.Questions { // Iterate over the game questions
+			record := answers[i]
+			result := AnswerResult{Question: i, Answered: record != nil}
+			if record != nil { // Only fill in the answer details if the player answered
+				result.Answer = record.Answer
+				result.Correct = game.Questions[i].IsCorrect(record.Answer)
+				result.Points = record.Points
+				result.Time = record.Latency.Milliseconds()
+				result.AnsweredAt = &record.Time
+				result.AnsweredBy = record.AnsweredBy
+			}
+			playerResult.Answers[i] = result
+		}
+		results.Players = append(results.Players, playerResult)
+	}
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		addPlayer(player, false)
+	})
+	game.LeftLock.Lock()
+	for _, player := range game.Left { // Include the players that left part way
+		addPlayer(player, true)
+	}
+	game.LeftLock.Unlock()
+	// Order the players from the highest score to the lowest
+	sort.SliceStable(results.Players, func(i, j int) bool {
+		return results.Players[i].Score > results.Players[j].Score
+	})
+	for team, score := range game.TeamScores() { // Collect the team scores for team games
+		results.Teams = append(results.Teams, TeamResult{Name: game.Teams[team], Score: score})
+	}
+	// Order the teams from the highest score to the lowest
+	sort.SliceStable(results.Teams, func(i, j int) bool {
+		return results.Teams[i].Score > results.Teams[j].Score
+	})
+	return &results
+}
+
+// StoreResults stores the results under a new download token so that they can
+// be downloaded later. Any expired results are removed. Returns the token
+func StoreResults(results *Results) Identifier {
+	ResultsLock.Lock() // Establish write lock on the results map
+	defer ResultsLock.Unlock()
+	pruneResults()
+	for {
+		token := CreateRandomId(16)
+		_, contains := StoredResults[token]
+		if !contains { // Check the token doesn't already exist
+			StoredResults[token] = results
+			return token
+		}
+	}
+}
+
+// StoreResultsAs stores the results with the provided token. Used for
+// self-paced games where the host is given the token before the game ends
+func StoreResultsAs(token Identifier, results *Results) {
+	ResultsLock.Lock() // Establish write lock on the results map
+	defer ResultsLock.Unlock()
+	pruneResults()
+	StoredResults[token] = results
+}
+
+// pruneResults removes any of the stored results that have expired. The
+// results lock must be held by the caller
+func pruneResults() {
+	for token, stored := range StoredResults { // Iterate over the stored results
+		if time.Since(stored.EndTime) >= ResultsLifetime { // If the results have expired
+			delete(StoredResults, token)
+		}
+	}
+}
+
+// GetResults retrieves the results stored with a matching token or nil if
+// there are none or if they have expired. Self-paced games that are still
+// open give the results so far
+func GetResults(token Identifier) *Results {
+	ResultsLock.RLock() // Establish a read lock on the results map
+	results, contains := StoredResults[token]
+	ResultsLock.RUnlock() // Release the read lock
+	if !contains {
+		for _, game := range All() { // Look for an open self-paced game with the token
+			if game.SelfPaced && game.ResultsToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(game.ResultsToken)) == 1 {
+				return game.CollectResults()
+			}
+		}
+		return nil
+	}
+	if time.Since(results.EndTime) >= ResultsLifetime {
+		return nil
+	}
+	return results
+}
+
+// WriteJSON writes the results to the provided writer as JSON
+func (results *Results) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// WriteCSV writes the results to the provided writer as CSV with one row
+// for each player for each question
+func (results *Results) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{
+		"player_id", "player_name", "question", "question_text", "answer",
+		"answer_text", "correct", "points", "response_time_ms", "answered_at", "team", "left",
+		"answered_by",
+	})
+	if err != nil {
+		return err
+	}
+	for _, player := range results.Players { // Iterate over the players
+		for _, answer := range player.Answers { // Iterate over the player answers
+			question := results.Questions[answer.Question]
+			row := []string{
+				player.Id,
+				player.Name,
+				strconv.Itoa(answer.Question + 1),
+				question.Question,
+				"", "", // Answer index and text are empty if not answered
+				strconv.FormatBool(answer.Correct),
+				strconv.FormatUint(uint64(answer.Points), 10),
+				"", "", // Response time and timestamp are empty if not answered
+				player.Team,
+				strconv.FormatBool(player.Left),
+				answer.AnsweredBy,
+			}
+			if answer.Answered { // Fill in the answer if the player answered
+				row[4] = strconv.Itoa(answer.Answer + 1)
+				if answer.Answer >= 0 && answer.Answer < len(question.Answers) {
+					row[5] = question.Answers[answer.Answer]
+				}
+				row[8] = strconv.FormatInt(answer.Time, 10)
+				row[9] = answer.AnsweredAt.Format(time.RFC3339Nano)
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	. "backend/tools"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newResultsGame creates a stopped game with one question and two players
+// that both answered it for testing the results
+func newResultsGame() (*Game, *Player, *Player) {
+	game := &Game{
+		Players:   NewPlayerStore(),
+		Questions: []QuestionData{{Question: "Q", Answers: []string{"A", "B"}, Values: []AnswerIndex{0}}},
+		State:     Stopped,
+	}
+	stayed := game.Players.NewPlayer(nil, "127.0.0.1:1", "Stayed")
+	left := game.Players.NewPlayer(nil, "127.0.0.1:2", "Left")
+	for _, player := range []*Player{stayed, left} {
+		game.Players.Add(player)
+		player.Answers[0] = &AnswerRecord{Question: 0, Answer: 0, Time: time.Now(), Points: 100}
+		player.Score = 100
+	}
+	return game, stayed, left
+}
+
+func TestResultsKeepLeftPlayers(t *testing.T) {
+	game, _, left := newResultsGame()
+	game.RemovePlayer(left)
+	results := game.CollectResults()
+	if len(results.Players) != 2 {
+		t.Fatalf("got %d players in the results, want 2", len(results.Players))
+	}
+	for _, player := range results.Players {
+		if player.Left != (player.Id == left.Id) {
+			t.Errorf("player %q has Left = %v", player.Name, player.Left)
+		}
+		if !player.Answers[0].Answered || player.Answers[0].Points != 100 {
+			t.Errorf("player %q lost their answer", player.Name)
+		}
+	}
+}
+
+func TestResultsWaitingPlayersNotKept(t *testing.T) {
+	game, _, left := newResultsGame()
+	game.State = Waiting
+	game.RemovePlayer(left)
+	if len(game.Left) != 0 {
+		t.Errorf("players leaving before the game starts shouldn't be kept")
+	}
+}
+
+func TestResultsZeroTime(t *testing.T) {
+	game, _, _ := newResultsGame()
+	results := game.CollectResults()
+
+	var out bytes.Buffer
+	if err := results.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"time": 0`) {
+		t.Errorf("JSON results are missing the 0ms answer time:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := results.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows[1:] {
+		if row[8] != "0" {
+			t.Errorf("CSV response_time_ms = %q, want 0", row[8])
+		}
+	}
+}
+
+func TestResultsTeamConsensus(t *testing.T) {
+	game, answerer, teammate := newResultsGame()
+	game.Teams = []string{"Team"}
+	game.TeamConsensus = true
+	answerer.Team, teammate.Team = 0, 0
+	teammate.Answers = map[QuestionIndex]*AnswerRecord{} // Only one player in the team answers
+	answerer.Score, teammate.Score = 0, 0
+	question := game.Questions[0]
+	game.MarkQuestion(&ActiveQuestion{Question: &question, Index: 0})
+
+	results := game.CollectResults()
+	for _, player := range results.Players {
+		answer := player.Answers[0]
+		if !answer.Answered || answer.Points == 0 || player.Score != answer.Points {
+			t.Errorf("player %q wasn't given the team answer: %+v", player.Name, answer)
+		}
+		want := Identifier("")
+		if player.Id == teammate.Id {
+			want = answerer.Id
+		}
+		if answer.AnsweredBy != want {
+			t.Errorf("player %q answered_by = %q, want %q", player.Name, answer.AnsweredBy, want)
+		}
+	}
+	if answerer.GetAnswer(0) == teammate.GetAnswer(0) {
+		t.Error("teammates shouldn't share the same answer record")
+	}
+}
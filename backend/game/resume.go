@@ -0,0 +1,92 @@
+package game
+
+import (
+	"backend/net"
+	. "backend/tools"
+	"crypto/subtle"
+	. "github.com/jacobtread/gowsps"
+)
+
+// ResumeHost attaches the provided connection as the host of the game and sends
+// it the current state of the game. If the game was restored from a snapshot
+// the game loop is started again. Returns false without attaching if the token
+// isn't the host token or the host is already connected
+func (game *Game) ResumeHost(conn *Connection, address string, token Identifier) bool {
+	game.HostsLock.Lock()
+	if game.Host != nil || subtle.ConstantTimeCompare([]byte(token), []byte(game.HostToken)) != 1 {
+		game.HostsLock.Unlock()
+		return false
+	}
+	game.Host = conn
+	game.HostAddress = address
+	game.HostsLock.Unlock()
+	game.SendHost(net.JoinGamePacket(true, game.Id, game.Title, game.HostToken))
+	game.SendHost(net.PresenterTokenPacket(game.PresenterToken))
+	game.SendHost(net.GameStatePacket(game.State))
+	game.SendAssignment(game.SendHost, true)
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		game.SendHost(net.PlayerDataPacket(id, player.Name, net.AddMode))
+	})
+	for _, player := range game.Players.GetPendingArray() { // Ask the host to approve any pending players again
+		game.SendHost(net.PendingPlayerPacket(player.Id, player.Name, net.AddMode))
+	}
+	game.SendBans(game.SendHost)
+	game.SendSpectatorCount(game.SendHost)
+	game.SendTeams(game.SendHost)
+	game.SendScores(game.SendHost)
+	game.SendHostQuestion()
+	if game.RestoredAt != 0 { // If the game is waiting for the host after being restored
+		game.resumeTimings()
+		go game.Loop() // Start a new goroutine for the game loop
+	}
+	game.Log("host_resume").Info("Host resumed game '%s'", game.Title)
+	return true
+}
+
+// resumeTimings moves the timings of a restored game forward by the time spent
+// waiting for the host so that they carry on from where they were when the
+// snapshot was taken. Games the host had paused stay paused at the same point
+// so unpausing only accounts for the time paused after the host is back
+func (game *Game) resumeTimings() {
+	waited := Time() - game.RestoredAt
+	game.StartTime += waited
+	if q := game.ActiveQuestion; q != nil {
+		q.StartTime += waited
+	}
+	if game.Paused {
+		game.PausedAt += waited
+	}
+	game.RestoredAt = 0
+}
+
+// ResumePlayer attaches the provided connection to the player and sends it the
+// current state of the game including the active question if the player can
+// still answer it
+func (game *Game) ResumePlayer(player *Player, conn *Connection, address string) {
+	player.Net = conn
+	player.Address = address
+	player.Send(net.JoinGamePacket(false, game.Id, game.Title, player.Token))
+	player.Send(net.GameStatePacket(game.State))
+	player.Send(net.PlayerDataPacket(player.Id, player.Name, net.SelfMode))
+	game.Players.ForEach(func(id Identifier, other *Player) {
+		if id != player.Id {
+			player.Send(net.PlayerDataPacket(id, other.Name, net.AddMode))
+		}
+	})
+	game.SendTeams(player.Send)
+	game.SendScores(player.Send)
+	game.SendAssignment(player.Send, false)
+	q := game.QuestionFor(player)
+	if game.State == Started && q != nil && !q.Marked && !player.HasAnswered(game) {
+		player.Send(game.QuestionPacketFor(player, q))
+		if game.SelfPaced { // Tell the player how long they have left on their own timer
+			if remaining := QuestionTime - (Time() - q.StartTime); remaining > 0 {
+				player.Send(net.TimeSyncPacket(QuestionTime, remaining))
+			}
+		}
+	}
+	if player.HasFinished() { // Self-paced players that finished see the end of the game
+		player.Send(net.GameStatePacket(Stopped))
+	}
+	game.Log("player_resume").WithPlayer(player.Id).Info("Player '%s' resumed game '%s'", player.Name, game.Title)
+}
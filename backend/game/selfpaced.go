@@ -0,0 +1,151 @@
+package game
+
+import (
+	"backend/net"
+	. "backend/tools"
+	"errors"
+	"fmt"
+	. "github.com/jacobtread/gowsps"
+	"time"
+)
+
+// MaxAssignmentLength The longest time that a self-paced game can stay open for
+const MaxAssignmentLength = 30 * 24 * time.Hour
+
+// CheckWindow checks the open and close times for a self-paced game which are
+// provided as unix timestamps in milliseconds. Games without an open time open
+// straight away. Returns the times or an error describing why they aren't allowed
+func CheckWindow(opens int64, closes int64) (time.Time, time.Time, error) {
+	now := time.Now()
+	openTime := now
+	if opens > 0 {
+		openTime = time.UnixMilli(opens)
+	}
+	if closes <= 0 {
+		return openTime, now, errors.New("Self-paced games need a close time")
+	}
+	closeTime := time.UnixMilli(closes)
+	if !closeTime.After(openTime) || !closeTime.After(now) {
+		return openTime, closeTime, errors.New("The close time must be after the open time and in the future")
+	}
+	if closeTime.Sub(now) > MaxAssignmentLength {
+		return openTime, closeTime, fmt.Errorf("Self-paced games can't be open for longer than %d days", MaxAssignmentLength/(24*time.Hour))
+	}
+	return openTime, closeTime, nil
+}
+
+// MakeSelfPaced turns the game into a self-paced game which is open between
+// the provided times and creates the token for downloading its results
+func (game *Game) MakeSelfPaced(opens time.Time, closes time.Time) {
+	game.Opens = opens
+	game.Closes = closes
+	game.ResultsToken = CreateRandomId(16)
+	game.SelfPaced = true
+}
+
+// QuestionFor retrieves the question that the player is currently answering.
+// In self-paced games each player has their own question, otherwise everyone
+// answers the active question of the game
+func (game *Game) QuestionFor(player *Player) *ActiveQuestion {
+	if game.SelfPaced {
+		return player.CurrentQuestion()
+	}
+	return game.ActiveQuestion
+}
+
+// SelfPacedTick moves a self-paced game along. Opens the game once the open
+// time is reached, moves each player through their questions and ends the
+// game at the close time. Called by the game loop instead of the usual timings
+func (game *Game) SelfPacedTick() {
+	now := time.Now()
+	if !now.Before(game.Closes) { // If the game has closed
+		game.Log("assignment_close").Info("Self-paced game '%s' closed", game.Title)
+		game.GameOver()
+		return
+	}
+	if game.State == Waiting {
+		if now.Before(game.Opens) { // If the game hasn't opened yet
+			return
+		}
+		game.SetState(Started)
+		game.Log("assignment_open").Info("Self-paced game '%s' opened", game.Title)
+	}
+	t := Time()
+	for _, player := range game.Players.GetPlayerArray() { // Move each of the players along
+		game.AdvancePlayer(player, t)
+	}
+}
+
+// AdvancePlayer moves the player through their questions. Questions are marked
+// once the player answers or their time runs out and the next question is sent
+// after the marking time. Players that have disconnected keep their place and
+// carry on from the next question when they resume
+func (game *Game) AdvancePlayer(player *Player, t time.Duration) {
+	if player.HasFinished() {
+		return
+	}
+	q := player.CurrentQuestion()
+	if q == nil || (q.Marked && t-q.MarkedAt >= MarkTime) { // If the player is ready for the next question
+		if player.Net != nil {
+			game.NextPlayerQuestion(player, t)
+		}
+	} else if !q.Marked && (t-q.StartTime >= QuestionTime || player.GetAnswer(q.Index) != nil) {
+		game.MarkAnswer(player, player.GetAnswer(q.Index), q)
+		marked := *q // Replace the question so answers and results can still read the old one
+		marked.Marked = true
+		marked.MarkedAt = t
+		player.SetQuestion(&marked, false)
+		game.BroadcastScores() // Update the shared leaderboard
+	}
+}
+
+// NextPlayerQuestion sends the player the question after the one they last
+// answered. Players that have answered every question are sent the Stopped
+// state to tell them they have finished
+func (game *Game) NextPlayerQuestion(player *Player, t time.Duration) {
+	next := 0
+	if q := player.CurrentQuestion(); q != nil {
+		next = q.Index + 1
+	}
+	if next >= len(game.Questions) { // If the player has answered every question
+		player.SetQuestion(nil, true)
+		player.Send(net.GameStatePacket(Stopped))
+		game.Log("player_finish").WithPlayer(player.Id).Info("Player '%s' finished '%s'", player.Name, game.Title)
+		return
+	}
+	question := game.Questions[next]
+	q := &ActiveQuestion{
+		Question:  &question,
+		Index:     next,
+		StartTime: t,
+	}
+	player.SetQuestion(q, false)
+	player.Send(game.QuestionPacketFor(player, q))
+	player.Send(net.TimeSyncPacket(QuestionTime, QuestionTime))
+}
+
+// PlayerLeft handles the player disconnecting from the game. Players in
+// self-paced games keep their place so that they stay on the leaderboard and
+// can resume later, otherwise the player is removed
+func (game *Game) PlayerLeft(player *Player) {
+	if game.SelfPaced && game.Players.Get(player.Id) == player {
+		player.Net = nil
+		game.Log("player_leave").WithPlayer(player.Id).Info("Player '%s' left self-paced game '%s'", player.Name, game.Title)
+		return
+	}
+	game.RemovePlayer(player)
+}
+
+// SendAssignment uses the provided send function to send the open and close
+// times of a self-paced game. The host is also sent the results token which
+// can be used to download the results at any time
+func (game *Game) SendAssignment(send func(packet Packet), host bool) {
+	if !game.SelfPaced {
+		return
+	}
+	token := ""
+	if host {
+		token = game.ResultsToken
+	}
+	send(net.AssignmentPacket(game.Opens, game.Closes, token))
+}
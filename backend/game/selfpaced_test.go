@@ -0,0 +1,60 @@
+package game
+
+import (
+	. "backend/tools"
+	"sync"
+	"testing"
+)
+
+// TestSelfPacedRace marks self-paced players on one goroutine while they
+// answer and the results and snapshots are taken on others. Run with -race
+func TestSelfPacedRace(t *testing.T) {
+	game := &Game{
+		Players:   NewPlayerStore(),
+		SelfPaced: true,
+		State:     Started,
+	}
+	for i := 0; i < 10; i++ {
+		game.Questions = append(game.Questions, QuestionData{Question: "Q", Answers: []string{"A", "B"}, Values: []AnswerIndex{0}})
+	}
+	player := game.Players.NewPlayer(nil, "127.0.0.1:1", "Player")
+	game.Players.Add(player)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	reader := func(read func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					read()
+				}
+			}
+		}()
+	}
+	reader(func() { // The player answering on their socket
+		if game.IsAnswerable(player) && !player.HasAnswered(game) {
+			player.Answer(game, 0)
+		}
+	})
+	reader(func() { game.CollectResults() }) // The host downloading the results so far
+	reader(func() { game.TakeSnapshot() })   // The periodic snapshots
+
+	for i := range game.Questions { // The game loop moving the player along
+		question := game.Questions[i]
+		player.SetQuestion(&ActiveQuestion{Question: &question, Index: i, StartTime: Time()}, false)
+		for q := player.CurrentQuestion(); !q.Marked; q = player.CurrentQuestion() {
+			game.AdvancePlayer(player, Time())
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if score := player.GetScore(); score == 0 {
+		t.Errorf("player answered correctly but has no score")
+	}
+}
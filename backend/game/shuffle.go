@@ -0,0 +1,75 @@
+package game
+
+import (
+	"backend/net"
+	. "backend/tools"
+	. "github.com/jacobtread/gowsps"
+	"math/rand"
+)
+
+// ShuffleQuestions puts the provided questions into a random order using the
+// provided seed. Used when creating games so that the questions aren't served
+// in the order they were written
+func ShuffleQuestions(questions []QuestionData, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+}
+
+// AnswerOrder retrieves the order that the answers to the question with the
+// provided index are shown to the player. The value at each displayed index
+// is the canonical index of the answer. Returns nil if the game doesn't
+// shuffle answers. The order comes from the player seed so it is the same
+// every time the question is sent to the player
+func (game *Game) AnswerOrder(player *Player, index QuestionIndex) []AnswerIndex {
+	if !game.ShuffleAnswers || index < 0 || index >= len(game.Questions) {
+		return nil
+	}
+	r := rand.New(rand.NewSource(player.Seed + int64(index)))
+	return r.Perm(len(game.Questions[index].Answers))
+}
+
+// CanonicalAnswer maps the answer index that the player chose from the
+// answers as they were shown to them back to the canonical answer index
+func (game *Game) CanonicalAnswer(player *Player, index QuestionIndex, displayed AnswerIndex) AnswerIndex {
+	order := game.AnswerOrder(player, index)
+	if order == nil || displayed < 0 || displayed >= len(order) {
+		return displayed
+	}
+	return order[displayed]
+}
+
+// QuestionPacketFor creates the question packet for the provided question with
+// the answers in the order they are shown to the player. Spectators, the
+// presenter and the host see the canonical order which is used when there
+// is no player
+func (game *Game) QuestionPacketFor(player *Player, q *ActiveQuestion) Packet {
+	question := *q.Question
+	if player == nil {
+		return net.QuestionPacket(question)
+	}
+	if order := game.AnswerOrder(player, q.Index); order != nil {
+		answers := make([]string, len(order))
+		for displayed, canonical := range order { // Put the answers into the player order
+			answers[displayed] = question.Answers[canonical]
+		}
+		question.Answers = answers
+	}
+	return net.QuestionPacket(question)
+}
+
+// BroadcastQuestion sends the question to everyone in the game. When answers
+// are shuffled each player is sent the answers in their own order
+func (game *Game) BroadcastQuestion(q *ActiveQuestion) {
+	if !game.ShuffleAnswers {
+		game.Broadcast(game.QuestionPacketFor(nil, q), false)
+		return
+	}
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		player.Send(game.QuestionPacketFor(player, q))
+	})
+	packet := game.QuestionPacketFor(nil, q)
+	game.SendSpectators(packet)
+	game.SendPresenter(packet)
+}
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"backend/logging"
+	"backend/net"
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownReason The reason given to players and hosts when their game is
+// ended because the server is shutting down
+const ShutdownReason = "The server is restarting for maintenance"
+
+// shuttingDown Whether the server is shutting down. Stored as an int32 so
+// that it can be accessed atomically
+var shuttingDown int32
+
+// IsShuttingDown Checks whether the server is shutting down in which case
+// no new games should be created
+func IsShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// Shutdown Stops the creation of new games and ends all the existing games.
+// Games that haven't started are closed straight away while games that are
+// in progress are warned and given up to the grace duration to finish before
+// they are closed. Blocks until all the games have been closed. If snapshots
+// are enabled the games are told the server is restarting and are saved
+// instead so that they can be resumed
+func Shutdown(grace time.Duration) {
+	atomic.StoreInt32(&shuttingDown, 1)
+	if SnapshotDir != "" { // If snapshots are enabled the games are restored on startup instead
+		for _, game := range All() { // Tell everyone the server is going down before saving
+			game.Broadcast(net.ShutdownPacket(0), true)
+		}
+		logging.Event("shutdown").Info("Saving snapshots of %d game(s)", len(All()))
+		SaveSnapshots()
+		return
+	}
+	deadline := time.Now().Add(grace)
+	logging.Event("shutdown").With("grace", grace.String()).Info("Shutting down %d game(s)", len(All()))
+
+	for _, game := range All() { // Iterate over all the games
+		if grace > 0 && !game.SelfPaced && (game.State == Starting || game.State == Started) {
+			// Warn the players and host that the game will be ended
+			game.Broadcast(net.ShutdownPacket(grace), true)
+		} else {
+			game.Close(ShutdownReason)
+		}
+	}
+
+	// Wait for the in progress games to finish or for the deadline to pass
+	for time.Now().Before(deadline) && len(All()) > 0 {
+		time.Sleep(time.Second)
+	}
+
+	for _, game := range All() { // Close any games that didn't finish in time
+		game.Close(ShutdownReason)
+	}
+}
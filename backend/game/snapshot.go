@@ -0,0 +1,420 @@
+package game
+
+import (
+	"backend/logging"
+	. "backend/tools"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RestoreTimeout The amount of time the host of a restored game has to
+// reconnect before the game is closed. This is the default which can be
+// changed by the config using Configure
+var RestoreTimeout = 2 * time.Minute
+
+// SnapshotDir The directory that game snapshots are stored in. Snapshots
+// are disabled when this is empty
+var SnapshotDir = ""
+
+type (
+	// Snapshot A structure representing the saved state of a game which can be
+	// written to disk and later restored
+	Snapshot struct {
+		Id             Identifier        `json:"id"`                        // The game code of the game
+		Title          string            `json:"title"`                     // The title of the game
+		HostToken      Identifier        `json:"host_token"`                // The session token of the host
+		PresenterToken Identifier        `json:"presenter_token"`           // The token used to attach the presenter display
+		HostAddress    string            `json:"host_address"`              // The remote address of the host
+		Password       string            `json:"password,omitempty"`        // The password required to join the game
+		Approval       bool              `json:"approval,omitempty"`        // Whether players must be approved by the host
+		LateJoins      bool              `json:"late_joins,omitempty"`      // Whether players can join after the game has started
+		LateMinimum    bool              `json:"late_minimum,omitempty"`    // Whether late joiners start with the lowest score
+		Teams          []string          `json:"teams,omitempty"`           // The names of the teams for team games
+		TeamScoring    TeamScoring       `json:"team_scoring,omitempty"`    // How the team scores are combined
+		TeamConsensus  bool              `json:"team_consensus,omitempty"`  // Whether only the first answer from each team counts
+		TeamPick       bool              `json:"team_pick,omitempty"`       // Whether players pick their own team
+		Bans           []*Ban            `json:"bans,omitempty"`            // The players banned from the game
+		Questions      []QuestionData    `json:"questions,omitempty"`       // The questions for the game, saved in their own file
+		Players        []PlayerSnapshot  `json:"players"`                   // The players in the game
+		Left           []PlayerSnapshot  `json:"left,omitempty"`            // The players that left after the game started
+		State          State             `json:"state"`                     // The state of the game
+		StartElapsed   time.Duration     `json:"start_elapsed"`             // The time passed since the game start time
+		ActiveQuestion *QuestionSnapshot `json:"active_question,omitempty"` // The active question if there is one
+		CreatedTime    time.Time         `json:"created_time"`              // The time the game was created
+		SavedTime      time.Time         `json:"saved_time"`                // The time the snapshot was taken
+
+		SelfPaced    bool       `json:"self_paced,omitempty"`    // Whether players answer at their own pace
+		Opens        time.Time  `json:"opens,omitempty"`         // The time a self-paced game opens
+		Closes       time.Time  `json:"closes,omitempty"`        // The time a self-paced game closes
+		ResultsToken Identifier `json:"results_token,omitempty"` // The token for downloading the results of a self-paced game
+
+		ShuffleAnswers bool  `json:"shuffle_answers,omitempty"` // Whether each player is shown the answers in their own order
+		Seed           int64 `json:"seed,omitempty"`            // The seed the questions were drawn and shuffled with
+
+		Paused bool `json:"paused,omitempty"` // Whether the host had paused the game
+	}
+
+	// PlayerSnapshot A structure representing the saved state of a player
+	PlayerSnapshot struct {
+		Id      Identifier                      `json:"id"`      // The id of the player
+		Token   Identifier                      `json:"token"`   // The session token of the player
+		Name    string                          `json:"name"`    // The name of the player
+		Address string                          `json:"address"` // The remote address of the player
+		Score   uint32                          `json:"score"`   // The score of the player
+		Team    int                             `json:"team"`    // The team the player is in
+		Answers map[QuestionIndex]*AnswerRecord `json:"answers"` // The answers of the player
+
+		Question *QuestionSnapshot `json:"question,omitempty"` // The question the player is answering in self-paced games
+		Finished bool              `json:"finished,omitempty"` // Whether the player has finished a self-paced game
+		Seed     int64             `json:"seed,omitempty"`     // The seed for the order the answers are shown in
+	}
+
+	// QuestionSnapshot A structure representing the saved state of the active question
+	QuestionSnapshot struct {
+		Index   QuestionIndex `json:"index"`   // The index of the question
+		Elapsed time.Duration `json:"elapsed"` // The time passed since the question started
+		Marked  bool          `json:"marked"`  // Whether the question has been marked
+	}
+)
+
+// TakeSnapshot creates a snapshot of the current state of the game
+func (game *Game) TakeSnapshot() *Snapshot {
+	t := Time()
+	if game.Paused { // Save the timings as they were when the game was paused
+		t = game.PausedAt
+	}
+	game.PasswordLock.RLock()
+	password := game.Password
+	game.PasswordLock.RUnlock()
+	snapshot := Snapshot{
+		Id:             game.Id,
+		Title:          game.Title,
+		HostToken:      game.HostToken,
+		PresenterToken: game.PresenterToken,
+		HostAddress:    game.HostAddress,
+		Password:       password,
+		Approval:       game.Approval,
+		LateJoins:      game.LateJoins,
+		LateMinimum:    game.LateMinimum,
+		Teams:          game.Teams,
+		TeamScoring:    game.TeamScoring,
+		TeamConsensus:  game.TeamConsensus,
+		TeamPick:       game.TeamPick,
+		Questions:      game.Questions,
+		State:          game.State,
+		StartElapsed:   t - game.StartTime,
+		CreatedTime:    game.CreatedTime,
+		SavedTime:      time.Now(),
+		SelfPaced:      game.SelfPaced,
+		Opens:          game.Opens,
+		Closes:         game.Closes,
+		ResultsToken:   game.ResultsToken,
+		ShuffleAnswers: game.ShuffleAnswers,
+		Seed:           game.Seed,
+		Paused:         game.Paused,
+	}
+	game.BansLock.RLock()
+	for _, ban := range game.Bans { // Save the bans
+		snapshot.Bans = append(snapshot.Bans, ban)
+	}
+	game.BansLock.RUnlock()
+	if q := game.ActiveQuestion; q != nil { // If the game has an active question
+		snapshot.ActiveQuestion = &QuestionSnapshot{
+			Index:   q.Index,
+			Elapsed: t - q.StartTime,
+			Marked:  q.Marked,
+		}
+	}
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		snapshot.Players = append(snapshot.Players, player.snapshot(t))
+	})
+	game.LeftLock.Lock()
+	for _, player := range game.Left { // Save the players that left for the results
+		snapshot.Left = append(snapshot.Left, player.snapshot(t))
+	}
+	game.LeftLock.Unlock()
+	return &snapshot
+}
+
+// snapshot creates a snapshot of the player. The provided time is used as the
+// current time for the timings of the player question
+func (player *Player) snapshot(t time.Duration) PlayerSnapshot {
+	answers := player.CopyAnswers()
+	player.AnswersLock.RLock() // Self-paced players can be marked while saving
+	defer player.AnswersLock.RUnlock()
+	p := PlayerSnapshot{
+		Id:       player.Id,
+		Token:    player.Token,
+		Name:     player.Name,
+		Address:  player.Address,
+		Score:    player.Score,
+		Team:     player.Team,
+		Answers:  answers,
+		Finished: player.Finished,
+		Seed:     player.Seed,
+	}
+	if q := player.Question; q != nil { // Save where self-paced players are up to
+		p.Question = &QuestionSnapshot{
+			Index:   q.Index,
+			Elapsed: t - q.StartTime,
+			Marked:  q.Marked,
+		}
+	}
+	return p
+}
+
+// Restore creates a game from the snapshot and adds it to Games. The game loop
+// isn't started until the host has reconnected and the game is closed if the
+// host doesn't reconnect within the RestoreTimeout. Self-paced games don't
+// need the host so their loop is started straight away
+func (snapshot *Snapshot) Restore() *Game {
+	t := Time()
+	game := Game{
+		HostAddress:    snapshot.HostAddress,
+		HostToken:      snapshot.HostToken,
+		PresenterToken: snapshot.PresenterToken,
+		Password:       snapshot.Password,
+		Approval:       snapshot.Approval,
+		LateJoins:      snapshot.LateJoins,
+		LateMinimum:    snapshot.LateMinimum,
+		Teams:          snapshot.Teams,
+		TeamScoring:    snapshot.TeamScoring,
+		TeamConsensus:  snapshot.TeamConsensus,
+		TeamPick:       snapshot.TeamPick,
+		Id:             snapshot.Id,
+		Title:          snapshot.Title,
+		Questions:      snapshot.Questions,
+		QuestionsSaved: true, // Restored games already have their questions saved
+		Players:        NewPlayerStore(),
+		StartTime:      t - snapshot.StartElapsed,
+		State:          snapshot.State,
+		CreatedTime:    snapshot.CreatedTime,
+		RestoredAt:     t,
+		SelfPaced:      snapshot.SelfPaced,
+		Opens:          snapshot.Opens,
+		Closes:         snapshot.Closes,
+		ResultsToken:   snapshot.ResultsToken,
+		ShuffleAnswers: snapshot.ShuffleAnswers,
+		Seed:           snapshot.Seed,
+		Paused:         snapshot.Paused,
+		PausedAt:       t, // The timings were saved as they were when the host paused
+	}
+	for _, ban := range snapshot.Bans { // Restore the bans
+		if game.Bans == nil {
+			game.Bans = map[Identifier]*Ban{}
+		}
+		game.Bans[ban.Id] = ban
+	}
+	if game.PresenterToken == "" { // Snapshots from older versions don't have a presenter token
+		game.PresenterToken = CreateRandomId(16)
+	}
+	if q := snapshot.ActiveQuestion; q != nil && q.Index >= 0 && q.Index < len(game.Questions) {
+		question := game.Questions[q.Index]
+		game.ActiveQuestion = &ActiveQuestion{
+			Question:  &question,
+			Index:     q.Index,
+			StartTime: t - q.Elapsed,
+			Marked:    q.Marked,
+		}
+	}
+	for _, p := range snapshot.Players { // Iterate over the saved players
+		game.Players.Map[p.Id] = game.restorePlayer(p, t)
+	}
+	for _, p := range snapshot.Left { // Restore the players that left for the results
+		game.Left = append(game.Left, game.restorePlayer(p, t))
+	}
+	GamesLock.Lock() // Establish write lock on the games map
+	Games[game.Id] = &game
+	GamesLock.Unlock() // Release write lock
+
+	if game.SelfPaced { // Self-paced games carry on without waiting for the host
+		game.RestoredAt = 0
+		go game.Loop()
+		return &game
+	}
+	time.AfterFunc(RestoreTimeout, func() {
+		if !game.HasHost() && game.State != Stopped { // If the host never came back
+			game.Log("restore_timeout").Info("Host didn't reconnect to restored game '%s'", game.Title)
+			game.Close("The host didn't reconnect to the game")
+		}
+	})
+	return &game
+}
+
+// restorePlayer creates a player from the snapshot of the player. The provided
+// time is used as the current time for the timings of the player question
+func (game *Game) restorePlayer(p PlayerSnapshot, t time.Duration) *Player {
+	answers := p.Answers
+	if answers == nil {
+		answers = map[QuestionIndex]*AnswerRecord{}
+	}
+	if !game.IsTeam(p.Team) { // Snapshots of games without teams
+		p.Team = NoTeam
+	}
+	player := &Player{
+		Id:       p.Id,
+		Token:    p.Token,
+		Name:     p.Name,
+		Address:  p.Address,
+		Score:    p.Score,
+		Team:     p.Team,
+		Answers:  answers,
+		Finished: p.Finished,
+		Seed:     p.Seed,
+	}
+	if q := p.Question; q != nil && q.Index >= 0 && q.Index < len(game.Questions) {
+		question := game.Questions[q.Index]
+		player.Question = &ActiveQuestion{
+			Question:  &question,
+			Index:     q.Index,
+			StartTime: t - q.Elapsed,
+			Marked:    q.Marked,
+			MarkedAt:  t - MarkTime, // Move on to the next question as soon as the player is back
+		}
+	}
+	return player
+}
+
+// questionsSuffix The suffix of the files the questions of each game are
+// saved in. These don't change so are only written once for each game
+const questionsSuffix = ".questions.json"
+
+// snapshotPath gets the path of the snapshot file for the provided game id
+func snapshotPath(id Identifier) string {
+	return filepath.Join(SnapshotDir, id+".json")
+}
+
+// questionsPath gets the path of the questions file for the provided game id
+func questionsPath(id Identifier) string {
+	return filepath.Join(SnapshotDir, id+questionsSuffix)
+}
+
+// writeFile writes the value as JSON to the provided path. The value is written
+// to a temporary file first so that a crash while writing doesn't leave a
+// broken file behind
+func writeFile(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// SaveSnapshot writes a snapshot of the game to the snapshot directory. The
+// questions are written to their own file the first time so that only the
+// state of the game is written each time. Games that have already been
+// removed aren't saved so that they don't come back after a restart
+func (game *Game) SaveSnapshot() error {
+	game.SnapshotLock.Lock()
+	defer game.SnapshotLock.Unlock()
+	if Get(game.Id) != game {
+		return nil
+	}
+	snapshot := game.TakeSnapshot()
+	if !game.QuestionsSaved {
+		if err := writeFile(questionsPath(game.Id), snapshot.Questions); err != nil {
+			return err
+		}
+		game.QuestionsSaved = true
+	}
+	snapshot.Questions = nil
+	return writeFile(snapshotPath(game.Id), snapshot)
+}
+
+// SaveSnapshots writes a snapshot for every game that hasn't stopped
+func SaveSnapshots() {
+	for _, game := range All() { // Iterate over all the games
+		if game.State == Stopped {
+			continue
+		}
+		if err := game.SaveSnapshot(); err != nil { // If the snapshot couldn't be saved
+			game.Log("snapshot").Error("Failed to save snapshot: %s", err)
+		}
+	}
+}
+
+// DeleteSnapshot removes the snapshot of the game with the provided id if
+// snapshots are enabled
+func DeleteSnapshot(id Identifier) {
+	if SnapshotDir == "" {
+		return
+	}
+	for _, path := range []string{snapshotPath(id), questionsPath(id)} {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) { // If the snapshot existed but couldn't be removed
+			logging.Event("snapshot").WithGame(id).Error("Failed to remove snapshot: %s", err)
+		}
+	}
+}
+
+// StartSnapshots enables snapshots in the provided directory, restores any
+// existing snapshots and starts a goroutine which saves snapshots of all the
+// games at the provided interval
+func StartSnapshots(dir string, interval time.Duration) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	SnapshotDir = dir
+	RestoreSnapshots()
+	go func() {
+		for {
+			time.Sleep(interval)
+			SaveSnapshots()
+		}
+	}()
+	return nil
+}
+
+// RestoreSnapshots restores all the games from the snapshots in the snapshot
+// directory. Snapshots that can't be read are logged and removed
+func RestoreSnapshots() {
+	entries, err := os.ReadDir(SnapshotDir)
+	if err != nil {
+		logging.Event("snapshot").Error("Failed to read snapshot directory: %s", err)
+		return
+	}
+	for _, entry := range entries { // Iterate over the snapshot files
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if id := strings.TrimSuffix(name, questionsSuffix); id != name { // Questions are read with their snapshot
+			if _, err := os.Stat(snapshotPath(id)); os.IsNotExist(err) { // Questions of a game that was never saved
+				_ = os.Remove(questionsPath(id))
+			}
+			continue
+		}
+		path := filepath.Join(SnapshotDir, name)
+		var snapshot Snapshot
+		err := readFile(path, &snapshot)
+		if err == nil && snapshot.Questions == nil { // Older snapshots have the questions in the same file
+			err = readFile(questionsPath(snapshot.Id), &snapshot.Questions)
+		}
+		if err != nil || snapshot.Id == "" { // If the snapshot is unreadable
+			logging.Event("snapshot").With("file", name).Error("Discarding unreadable snapshot: %v", err)
+			_ = os.Remove(path)
+			if snapshot.Id != "" {
+				_ = os.Remove(questionsPath(snapshot.Id))
+			}
+			continue
+		}
+		game := snapshot.Restore()
+		game.Log("snapshot_restore").Info("Restored game '%s' with %d player(s)", game.Title, len(snapshot.Players))
+	}
+}
+
+// readFile reads the JSON at the provided path into the value
+func readFile(path string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
@@ -0,0 +1,113 @@
+package game
+
+import (
+	. "backend/tools"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// newSnapshotGame creates a started game in the games map with snapshots
+// saved to a temporary directory
+func newSnapshotGame(t *testing.T, id Identifier) *Game {
+	SnapshotDir = t.TempDir()
+	t.Cleanup(func() { SnapshotDir = "" })
+	game := &Game{
+		Id:        id,
+		Title:     "Snapshot",
+		Players:   NewPlayerStore(),
+		Questions: []QuestionData{{Question: "Q", Answers: []string{"A", "B"}, Values: []AnswerIndex{0}}},
+		State:     Started,
+		StartTime: Time(),
+	}
+	GamesLock.Lock()
+	Games[id] = game
+	GamesLock.Unlock()
+	t.Cleanup(game.Remove)
+	return game
+}
+
+func TestSnapshotQuestionsSavedOnce(t *testing.T) {
+	game := newSnapshotGame(t, "SNAP01")
+	if err := game.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(questionsPath(game.Id)); err != nil {
+		t.Fatalf("questions weren't saved: %s", err)
+	}
+	var state map[string]json.RawMessage
+	if err := readFile(snapshotPath(game.Id), &state); err != nil {
+		t.Fatal(err)
+	}
+	if _, contains := state["questions"]; contains {
+		t.Error("the periodic snapshot shouldn't contain the questions")
+	}
+
+	// The questions aren't written again by later snapshots
+	if err := os.Remove(questionsPath(game.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := game.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(questionsPath(game.Id)); !os.IsNotExist(err) {
+		t.Error("questions were written again")
+	}
+}
+
+func TestSnapshotNotSavedAfterRemove(t *testing.T) {
+	game := newSnapshotGame(t, "SNAP02")
+	if err := game.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	game.Remove()
+	if err := game.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{snapshotPath(game.Id), questionsPath(game.Id)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists after the game was removed", path)
+		}
+	}
+}
+
+func TestSnapshotHostPauseRestore(t *testing.T) {
+	game := newSnapshotGame(t, "SNAP03")
+	question := game.Questions[0]
+	game.StartTime = Time() - 3*time.Second
+	game.ActiveQuestion = &ActiveQuestion{Question: &question, StartTime: Time() - 2*time.Second}
+	game.Pause()
+	if err := game.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the game as if the server had restarted
+	var snapshot Snapshot
+	if err := readFile(snapshotPath(game.Id), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := readFile(questionsPath(game.Id), &snapshot.Questions); err != nil {
+		t.Fatal(err)
+	}
+	game.Remove()
+	restored := snapshot.Restore()
+	t.Cleanup(restored.Remove)
+	if !restored.Paused {
+		t.Fatal("the restored game should still be paused")
+	}
+
+	time.Sleep(100 * time.Millisecond) // Waiting for the host to come back
+	restored.resumeTimings()
+	time.Sleep(100 * time.Millisecond) // Paused after the host is back
+	restored.Unpause()
+
+	// The timings carry on from where they were when the host paused
+	const tolerance = 50 * time.Millisecond
+	if elapsed := Time() - restored.StartTime; elapsed < 3*time.Second || elapsed > 3*time.Second+tolerance {
+		t.Errorf("game elapsed %s after unpausing, want 3s", elapsed)
+	}
+	if elapsed := Time() - restored.ActiveQuestion.StartTime; elapsed < 2*time.Second || elapsed > 2*time.Second+tolerance {
+		t.Errorf("question elapsed %s after unpausing, want 2s", elapsed)
+	}
+}
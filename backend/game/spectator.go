@@ -0,0 +1,89 @@
+package game
+
+import (
+	"backend/net"
+	. "github.com/jacobtread/gowsps"
+)
+
+// AddSpectator attaches the connection to the game as a spectator. Spectators
+// receive everything that is broadcast to the game but aren't players so they
+// can't answer and aren't included in the player lists. The spectator is sent
+// the current state of the game and the host is told how many spectators there
+// are. Returns false if the game has too many spectators
+func (game *Game) AddSpectator(conn *Connection) bool {
+	game.SpectatorsLock.Lock()
+	if len(game.Spectators) >= MaxSpectators { // If the game has too many spectators
+		game.SpectatorsLock.Unlock()
+		return false
+	}
+	if game.Spectators == nil {
+		game.Spectators = map[*Connection]bool{}
+	}
+	game.Spectators[conn] = true
+	count := len(game.Spectators)
+	game.SpectatorsLock.Unlock()
+	game.SendHost(net.SpectatorsPacket(count))
+
+	send := func(packet Packet) { net.Send(conn, packet) }
+	send(net.SpectatingPacket(game.Id, game.Title, false))
+	game.SendOverview(send)
+	game.Log("spectator_join").Info("Spectator started watching '%s'", game.Title)
+	return true
+}
+
+// SendOverview uses the provided send function to send the state of the game,
+// the players in the game, the current progress and the scores. Used to bring
+// spectators and presenters up to date when they attach
+func (game *Game) SendOverview(send func(packet Packet)) {
+	send(net.GameStatePacket(game.State))
+	game.Players.ForEach(func(id string, player *Player) { // Send the players in the game
+		send(net.PlayerDataPacket(id, player.Name, net.AddMode))
+	})
+	game.SendTeams(send)
+	game.SendProgress(send, nil)
+	game.SendScores(send)
+}
+
+// RemoveSpectator detaches the spectator connection from the game
+func (game *Game) RemoveSpectator(conn *Connection) {
+	game.SpectatorsLock.Lock()
+	_, exists := game.Spectators[conn]
+	delete(game.Spectators, conn)
+	count := len(game.Spectators)
+	game.SpectatorsLock.Unlock()
+	if exists {
+		game.SendHost(net.SpectatorsPacket(count))
+		game.Log("spectator_leave").Info("Spectator stopped watching '%s'", game.Title)
+	}
+}
+
+// SpectatorCount retrieves the number of spectators watching the game
+func (game *Game) SpectatorCount() int {
+	game.SpectatorsLock.RLock()
+	defer game.SpectatorsLock.RUnlock()
+	return len(game.Spectators)
+}
+
+// SendSpectatorCount uses the provided send function to send the number of
+// spectators watching the game. Used to bring hosts up to date
+func (game *Game) SendSpectatorCount(send func(packet Packet)) {
+	send(net.SpectatorsPacket(game.SpectatorCount()))
+}
+
+// SendSpectators sends the provided packet to all the spectators
+func (game *Game) SendSpectators(packet Packet) {
+	game.SpectatorsLock.RLock()
+	defer game.SpectatorsLock.RUnlock()
+	for conn := range game.Spectators {
+		net.Send(conn, packet)
+	}
+}
+
+// DisconnectSpectators sends all the spectators a disconnect packet with
+// the provided reason and detaches them from the game
+func (game *Game) DisconnectSpectators(reason string) {
+	game.SendSpectators(net.DisconnectPacket(reason))
+	game.SpectatorsLock.Lock()
+	game.Spectators = nil
+	game.SpectatorsLock.Unlock()
+}
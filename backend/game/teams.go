@@ -0,0 +1,258 @@
+package game
+
+import (
+	"backend/names"
+	"backend/net"
+	. "backend/tools"
+	"errors"
+	"fmt"
+	. "github.com/jacobtread/gowsps"
+	"sort"
+)
+
+// NoTeam The team of players in games that aren't played in teams
+const NoTeam = -1
+
+// MaxTeams The maximum number of teams a game can have
+const MaxTeams = 16
+
+// TeamScoring The way the scores of the players in a team are combined into
+// the score of the team
+type TeamScoring = string
+
+const (
+	SumScoring     TeamScoring = "sum"     // The team score is the total of the player scores
+	AverageScoring TeamScoring = "average" // The team score is the average of the player scores
+	BestScoring    TeamScoring = "best"    // The team score is the highest of the player scores
+)
+
+// CheckTeams cleans the provided team names and checks that they are allowed.
+// Games without teams have no names. Returns the cleaned names or an error
+// describing why they aren't allowed
+func CheckTeams(teams []string) ([]string, error) {
+	if len(teams) == 0 { // Not a team game
+		return nil, nil
+	}
+	if len(teams) < 2 {
+		return nil, errors.New("Team games need at least two teams")
+	}
+	if len(teams) > MaxTeams {
+		return nil, fmt.Errorf("Games can't have more than %d teams", MaxTeams)
+	}
+	out := make([]string, len(teams))
+	for i, team := range teams { // Iterate over the team names
+		name, err := names.Check(team)
+		if err != nil {
+			return nil, fmt.Errorf("Team %d: %s", i+1, err.Error())
+		}
+		for _, other := range out[:i] { // Make sure the teams can be told apart
+			if names.Collides(name, other) {
+				return nil, fmt.Errorf("There is already a team called '%s'", other)
+			}
+		}
+		out[i] = name
+	}
+	return out, nil
+}
+
+// CheckTeamScoring checks that the provided team scoring is known, falling
+// back to SumScoring when none is provided. Every teammate is given the team
+// answer when consensus is required so summing would count it once for each
+// player, those games fall back to BestScoring and can't use SumScoring
+func CheckTeamScoring(scoring string, consensus bool) (TeamScoring, error) {
+	switch {
+	case scoring == "" && consensus:
+		return BestScoring, nil
+	case scoring == SumScoring && consensus:
+		return "", errors.New("Teams that require consensus must use average or best scoring")
+	}
+	switch scoring {
+	case "":
+		return SumScoring, nil
+	case SumScoring, AverageScoring, BestScoring:
+		return scoring, nil
+	default:
+		return "", fmt.Errorf("Unknown team scoring '%s'", scoring)
+	}
+}
+
+// HasTeams checks whether the game is played in teams
+func (game *Game) HasTeams() bool {
+	return len(game.Teams) > 0
+}
+
+// IsTeam checks whether the provided team index is one of the game teams
+func (game *Game) IsTeam(team int) bool {
+	return team >= 0 && team < len(game.Teams)
+}
+
+// TeamSizes counts the number of players in each of the teams
+func (game *Game) TeamSizes() []int {
+	sizes := make([]int, len(game.Teams))
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		if game.IsTeam(player.Team) {
+			sizes[player.Team]++
+		}
+	})
+	return sizes
+}
+
+// AssignTeam places a player that is joining the game into a team. Players
+// keep the team they picked if the game lets players pick, otherwise they are
+// placed in the team with the fewest players
+func (game *Game) AssignTeam(player *Player) {
+	if !game.HasTeams() {
+		player.Team = NoTeam
+		return
+	}
+	game.TeamsLock.Lock()
+	defer game.TeamsLock.Unlock()
+	if game.TeamPick && game.IsTeam(player.Team) { // Keep the team the player picked
+		return
+	}
+	sizes := game.TeamSizes()
+	smallest := 0
+	for team, size := range sizes { // Find the team with the fewest players
+		if size < sizes[smallest] {
+			smallest = team
+		}
+	}
+	player.Team = smallest
+}
+
+// SetTeam moves the player into the provided team and tells everyone in the
+// game which team they are now in
+func (game *Game) SetTeam(player *Player, team int) {
+	game.TeamsLock.Lock()
+	player.Team = team
+	game.TeamsLock.Unlock()
+	game.Broadcast(net.PlayerTeamPacket(player.Id, team), true)
+	game.Log("player_team").WithPlayer(player.Id).With("team", team).Debug("Player '%s' moved to team '%s'", player.Name, game.Teams[team])
+}
+
+// BalanceTeams reassigns every player so that the teams are as even as
+// possible. Players are dealt out from the highest score to the lowest going
+// back and forth across the teams so that the best players are spread out
+func (game *Game) BalanceTeams() {
+	players := game.Players.GetPlayerArray()
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].GetScore() > players[j].GetScore()
+	})
+	count := len(game.Teams)
+	for i, player := range players { // Deal the players out across the teams
+		team := i % count
+		if (i/count)%2 == 1 { // Go back the other way every other round
+			team = count - 1 - team
+		}
+		game.SetTeam(player, team)
+	}
+	game.Log("teams_balance").Info("Balanced the teams in '%s'", game.Title)
+}
+
+// TeamRecords retrieves the answer records for the provided question mapped
+// to the team of the player that answered. With consensus each team only has
+// one answer so this is the answer that counts for the whole team
+func (game *Game) TeamRecords(index QuestionIndex) map[int]*AnswerRecord {
+	out := map[int]*AnswerRecord{}
+	for team, player := range game.TeamAnswerers(index) {
+		out[team] = player.GetAnswer(index)
+	}
+	return out
+}
+
+// TeamAnswerers retrieves the player in each team that answered the provided
+// question first mapped to the team. Answers copied to teammates when marking
+// aren't counted
+func (game *Game) TeamAnswerers(index QuestionIndex) map[int]*Player {
+	out := map[int]*Player{}
+	first := map[int]*AnswerRecord{}
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		record := player.GetAnswer(index)
+		if record == nil || record.AnsweredBy != "" || !game.IsTeam(player.Team) {
+			return
+		}
+		if existing, exists := first[player.Team]; !exists || record.Time.Before(existing.Time) {
+			first[player.Team] = record
+			out[player.Team] = player
+		}
+	})
+	return out
+}
+
+// SubmitAnswer submits the player answer to the active question. When the
+// game requires team consensus only the first answer from each team counts so
+// false is returned if someone in the player's team has already answered
+func (game *Game) SubmitAnswer(player *Player, id AnswerIndex) bool {
+	if !game.TeamConsensus {
+		player.Answer(game, id)
+		return true
+	}
+	// Hold the lock so that two players in a team can't both answer at once
+	game.TeamsLock.Lock()
+	defer game.TeamsLock.Unlock()
+	if _, answered := game.TeamRecords(game.ActiveQuestion.Index)[player.Team]; answered {
+		return false
+	}
+	player.Answer(game, id)
+	return true
+}
+
+// TeamScores combines the scores of the players in each team using the team
+// scoring of the game. Teams without any players have a score of zero
+func (game *Game) TeamScores() []uint32 {
+	scores := make([]uint32, len(game.Teams))
+	sizes := make([]uint32, len(game.Teams))
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		team := player.Team
+		if !game.IsTeam(team) {
+			return
+		}
+		sizes[team]++
+		score := player.GetScore()
+		if game.TeamScoring == BestScoring {
+			if score > scores[team] {
+				scores[team] = score
+			}
+		} else {
+			scores[team] += score
+		}
+	})
+	if game.TeamScoring == AverageScoring {
+		for team, size := range sizes { // Divide the totals by the number of players
+			if size > 0 {
+				scores[team] /= size
+			}
+		}
+	}
+	return scores
+}
+
+// SendTeams uses the provided send function to send the teams of the game
+// and which team each of the players is in. Does nothing if the game isn't
+// played in teams
+func (game *Game) SendTeams(send func(packet Packet)) {
+	if !game.HasTeams() {
+		return
+	}
+	send(net.TeamsPacket(game.Teams, game.TeamScoring, game.TeamConsensus, game.TeamPick))
+	game.Players.ForEach(func(id Identifier, player *Player) {
+		send(net.PlayerTeamPacket(id, player.Team))
+	})
+}
+
+// SendScores uses the provided send function to send the player scores and
+// the team scores if the game is played in teams
+func (game *Game) SendScores(send func(packet Packet)) {
+	send(net.ScoresPacket(game.Players.CollectScores()))
+	if game.HasTeams() {
+		send(net.TeamScoresPacket(game.TeamScores()))
+	}
+}
+
+// BroadcastScores sends the player scores and team scores to everyone in the
+// game including the host
+func (game *Game) BroadcastScores() {
+	game.SendScores(func(packet Packet) {
+		game.Broadcast(packet, true)
+	})
+}
@@ -0,0 +1,26 @@
+package game
+
+import "testing"
+
+func TestCheckTeamScoringConsensus(t *testing.T) {
+	tests := []struct {
+		scoring   string
+		consensus bool
+		want      TeamScoring
+		fails     bool
+	}{
+		{"", false, SumScoring, false},
+		{"", true, BestScoring, false},
+		{SumScoring, false, SumScoring, false},
+		{SumScoring, true, "", true}, // The team answer would be counted once per teammate
+		{AverageScoring, true, AverageScoring, false},
+		{BestScoring, true, BestScoring, false},
+		{"most", false, "", true},
+	}
+	for _, test := range tests {
+		scoring, err := CheckTeamScoring(test.scoring, test.consensus)
+		if (err != nil) != test.fails || scoring != test.want {
+			t.Errorf("CheckTeamScoring(%q, %v) = %q, %v", test.scoring, test.consensus, scoring, err)
+		}
+	}
+}
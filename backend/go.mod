@@ -2,7 +2,10 @@ module backend
 
 go 1.18
 
-require github.com/jacobtread/gowsps v0.0.0-20220307042916-78f2facec237
+require (
+	github.com/jacobtread/gowsps v0.0.0-20220307042916-78f2facec237
+	golang.org/x/text v0.14.0
+)
 
 require (
 	github.com/gorilla/websocket v1.5.0 // indirect
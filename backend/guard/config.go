@@ -0,0 +1,47 @@
+package guard
+
+import (
+	"backend/config"
+	. "backend/net"
+	"net"
+)
+
+// Configure Sets the allowed origins, trusted proxies, connection limits,
+// packet rate limits and game lookup lockouts from the provided config
+func Configure(config *config.Config) {
+	AllowedOrigins = config.Access.AllowedOrigins
+	TrustedProxies = parseProxies(config.Access.TrustedProxies)
+	MaxConnections = config.Limits.MaxConnections
+	MaxConnectionsPerIP = config.Limits.MaxConnectionsPerIP
+	MaxMessageSize = config.Limits.MaxMessageSize
+
+	RateLimited = config.RateLimit.Enabled
+	Rates = map[int]Rate{
+		CCreateGame:       parseRate(config.RateLimit.CreateGame),
+		CCheckNameTaken:   parseRate(config.RateLimit.CheckName),
+		CRequestGameState: parseRate(config.RateLimit.GameState),
+		CRequestJoin:      parseRate(config.RateLimit.Join),
+	}
+	DefaultRate = parseRate(config.RateLimit.Other)
+	IPMultiplier = config.RateLimit.IPMultiplier
+	MaxViolations = config.RateLimit.MaxViolations
+	ViolationDecay = config.RateLimit.ViolationDecay
+	LookupFailures = config.RateLimit.LookupFailures
+	LookupIPFailures = config.RateLimit.LookupIPFailures
+	LookupWindow = config.RateLimit.LookupWindow
+	LookupLockout = config.RateLimit.LookupLockout
+}
+
+// parseRate Parses a rate from the config. The rates have already been
+// checked when the config was validated
+func parseRate(raw string) Rate {
+	count, per, _ := config.ParseRate(raw)
+	return Rate{Count: count, Per: per}
+}
+
+// parseProxies Parses the trusted proxies from the config. The proxies have
+// already been checked when the config was validated
+func parseProxies(entries []string) []*net.IPNet {
+	networks, _ := config.ParseProxies(entries)
+	return networks
+}
@@ -0,0 +1,66 @@
+package guard
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Connection limits. These are the defaults which can be changed by the
+// config using Configure
+var (
+	MaxConnections      = 10000 // The maximum number of sockets connected to the server
+	MaxConnectionsPerIP = 0     // The maximum number of sockets connected from a single IP, 0 for no limit
+)
+
+var (
+	connections     = map[string]int{} // The number of sockets connected for each IP
+	connectionTotal = 0                // The total number of sockets connected
+	connectionsLock sync.Mutex         // A lock for the connection counts
+)
+
+// ClientIP Retrieves the IP address of the client that made the request
+// without the port so that all connections from one machine share limits.
+// Requests through trusted proxies use the forwarded IP (see ClientAddress)
+func ClientIP(r *http.Request) string {
+	return AddressIP(ClientAddress(r))
+}
+
+// AddressIP Retrieves the IP from the provided remote address without the port
+func AddressIP(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil { // If the address has no port use it as it is
+		return address
+	}
+	return host
+}
+
+// Acquire Attempts to take a connection slot for the provided IP. Returns
+// an empty string if the slot was taken otherwise the reason it wasn't.
+// Release must be called once the connection closes if the slot was taken
+func Acquire(ip string) string {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	if connectionTotal >= MaxConnections { // If the server is full
+		return "server_full"
+	}
+	// Whole classrooms often share one IP so the IP limit is only used when set
+	if MaxConnectionsPerIP > 0 && connections[ip] >= MaxConnectionsPerIP { // If the IP has too many connections
+		return "ip_limit"
+	}
+	connections[ip]++
+	connectionTotal++
+	return ""
+}
+
+// Release Releases a connection slot that was taken for the provided IP
+func Release(ip string) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	connectionTotal--
+	if connections[ip] <= 1 { // Remove the IP so the map doesn't grow forever
+		delete(connections, ip)
+	} else {
+		connections[ip]--
+	}
+}
@@ -0,0 +1,127 @@
+package guard
+
+import (
+	"sync"
+	"time"
+)
+
+// Game lookup lockouts. These are the defaults which can be changed by the
+// config using Configure
+var (
+	LookupFailures   = 10              // The failures a connection can make within the window
+	LookupIPFailures = 100             // The failures all the connections from an IP can make within the window
+	LookupWindow     = time.Minute     // The window that failures are counted within
+	LookupLockout    = 5 * time.Minute // How long a connection or IP is locked out for
+)
+
+// FailureKind The kind of failed lookup. Each kind has its own budget so
+// that mistyped passwords don't use up the attempts at entering a game code
+type FailureKind int
+
+// The kinds of failed lookups
+const (
+	CodeFailure   FailureKind = iota // A game code that doesn't exist
+	SecretFailure                    // A wrong game password or presenter token
+	failureKinds                     // The number of kinds of failures
+)
+
+// lookupRecord A structure representing the failed lookups of one kind for a
+// connection or IP
+type lookupRecord struct {
+	failures    int       // The number of failed lookups in the current window
+	windowStart time.Time // The time the current window started
+	lockedUntil time.Time // The time the connection or IP is locked out until
+}
+
+// fail Records a failure at the provided time. Returns whether the failures
+// reached the provided limit and the record is now locked out
+func (record *lookupRecord) fail(limit int, now time.Time) bool {
+	if now.Sub(record.windowStart) > LookupWindow { // Start a new window
+		record.failures = 0
+		record.windowStart = now
+	}
+	record.failures++
+	if record.failures >= limit { // Lock out once there have been too many failures
+		record.lockedUntil = now.Add(LookupLockout)
+		record.failures = 0
+		record.windowStart = now
+		return true
+	}
+	return false
+}
+
+// ipLookupKey The key of the failed lookups of one kind for an IP
+type ipLookupKey struct {
+	ip   string
+	kind FailureKind
+}
+
+var (
+	ipLookups   = map[ipLookupKey]*lookupRecord{} // The failed lookups for each IP
+	lookupsLock sync.Mutex                        // A lock for the failed lookups of IPs
+)
+
+// Lookups A structure which tracks the failed lookups of a single connection.
+// Connections are locked out after LookupFailures while the IP they share is
+// only locked out after the much higher LookupIPFailures, so that a few people
+// mistyping behind one school network don't lock everyone else out
+type Lookups struct {
+	IP      string                     // The IP of the connection
+	records [failureKinds]lookupRecord // The failed lookups of the connection for each kind
+	lock    sync.Mutex                 // A lock for the records
+}
+
+// NewLookups Creates a new failed lookup tracker for a connection from the
+// provided IP
+func NewLookups(ip string) *Lookups {
+	sweepStart.Do(func() { go sweepIPs() })
+	return &Lookups{IP: ip}
+}
+
+// IsLockedOut Checks whether the connection or its IP is locked out from
+// lookups of the provided kind
+func (lookups *Lookups) IsLockedOut(kind FailureKind) bool {
+	now := time.Now()
+	lookups.lock.Lock()
+	locked := now.Before(lookups.records[kind].lockedUntil)
+	lookups.lock.Unlock()
+	if locked {
+		return true
+	}
+	lookupsLock.Lock()
+	defer lookupsLock.Unlock()
+	record, exists := ipLookups[ipLookupKey{lookups.IP, kind}]
+	return exists && now.Before(record.lockedUntil)
+}
+
+// RecordFailure Records a failed lookup of the provided kind against the
+// connection and its IP. Returns whether either has now been locked out
+func (lookups *Lookups) RecordFailure(kind FailureKind) bool {
+	now := time.Now()
+	lookups.lock.Lock()
+	locked := lookups.records[kind].fail(LookupFailures, now)
+	lookups.lock.Unlock()
+
+	lookupsLock.Lock()
+	defer lookupsLock.Unlock()
+	key := ipLookupKey{lookups.IP, kind}
+	record, exists := ipLookups[key]
+	if !exists {
+		record = &lookupRecord{windowStart: now}
+		ipLookups[key] = record
+	}
+	return record.fail(LookupIPFailures, now) || locked
+}
+
+// sweepLookups Removes the failed lookups of IPs that aren't locked out and
+// whose window has ended
+func sweepLookups() {
+	now := time.Now()
+	lookupsLock.Lock()
+	defer lookupsLock.Unlock()
+	for key, record := range ipLookups {
+		if now.After(record.lockedUntil) && now.Sub(record.windowStart) > LookupWindow {
+			delete(ipLookups, key)
+		}
+	}
+}
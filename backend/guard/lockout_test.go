@@ -0,0 +1,57 @@
+package guard
+
+import "testing"
+
+// withLookupLimits sets the lookup failure limits until the test finishes
+func withLookupLimits(t *testing.T, connection int, ip int) {
+	failures, ipFailures := LookupFailures, LookupIPFailures
+	LookupFailures, LookupIPFailures = connection, ip
+	t.Cleanup(func() { LookupFailures, LookupIPFailures = failures, ipFailures })
+}
+
+func TestLookupsConnectionLockout(t *testing.T) {
+	withLookupLimits(t, 3, 10)
+	mistyped, other := NewLookups("192.0.2.20"), NewLookups("192.0.2.20")
+	for i := 0; i < 3; i++ {
+		mistyped.RecordFailure(CodeFailure)
+	}
+	if !mistyped.IsLockedOut(CodeFailure) {
+		t.Error("the connection should be locked out after too many codes")
+	}
+	if other.IsLockedOut(CodeFailure) {
+		t.Error("other connections from the same IP shouldn't be locked out")
+	}
+	if mistyped.IsLockedOut(SecretFailure) {
+		t.Error("wrong codes shouldn't lock the connection out from passwords")
+	}
+}
+
+func TestLookupsSeparateBudgets(t *testing.T) {
+	withLookupLimits(t, 3, 10)
+	lookups := NewLookups("192.0.2.21")
+	for i := 0; i < 3; i++ {
+		lookups.RecordFailure(SecretFailure)
+	}
+	if !lookups.IsLockedOut(SecretFailure) {
+		t.Error("the connection should be locked out after too many passwords")
+	}
+	if lookups.IsLockedOut(CodeFailure) {
+		t.Error("wrong passwords shouldn't use up the game code attempts")
+	}
+}
+
+func TestLookupsIPLockout(t *testing.T) {
+	withLookupLimits(t, 3, 10)
+	var locked bool
+	for i := 0; i < 5; i++ { // Five students each mistype the code twice
+		lookups := NewLookups("192.0.2.22")
+		lookups.RecordFailure(CodeFailure)
+		locked = lookups.RecordFailure(CodeFailure)
+	}
+	if !locked || !NewLookups("192.0.2.22").IsLockedOut(CodeFailure) {
+		t.Error("the IP should be locked out once it reaches the shared limit")
+	}
+	if NewLookups("192.0.2.23").IsLockedOut(CodeFailure) {
+		t.Error("other IPs shouldn't be locked out")
+	}
+}
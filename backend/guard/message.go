@@ -0,0 +1,147 @@
+package guard
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// MaxMessageSize The maximum size in bytes of a single websocket message
+// received from a client. This is the default which can be changed by the
+// config using Configure
+var MaxMessageSize = 16 * 1024 * 1024
+
+// ErrMessageTooLarge The error returned when reading from a connection which
+// sent a message larger than the MaxMessageSize
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// Socket A response writer for the websocket upgrade which keeps hold of the
+// connection taken over by the upgrade. This allows the server to close the
+// connection and limit the size of messages as the websocket library doesn't
+// provide either. Messages larger than MaxMessageSize fail to be read
+type Socket struct {
+	http.ResponseWriter
+
+	conn net.Conn   // The connection once it has been taken over
+	lock sync.Mutex // A lock for accessing the connection
+}
+
+// NewSocket Wraps the response writer for a websocket upgrade
+func NewSocket(w http.ResponseWriter) *Socket {
+	return &Socket{ResponseWriter: w}
+}
+
+// Hijack Takes over the connection wrapping it with a limitedConn so that the
+// frame headers are read as they pass through the connection which stops large
+// messages being buffered
+func (socket *Socket) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := socket.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	socket.lock.Lock()
+	socket.conn = conn
+	socket.lock.Unlock()
+	if MaxMessageSize <= 0 || rw.Reader.Buffered() > 0 { // Data sent before the upgrade is rejected by the websocket library
+		return conn, rw, nil
+	}
+	limited := &limitedConn{Conn: conn, max: int64(MaxMessageSize)}
+	return limited, bufio.NewReadWriter(bufio.NewReader(limited), rw.Writer), nil
+}
+
+// Close Closes the connection if it has been taken over. This causes the
+// websocket library to stop listening for packets
+func (socket *Socket) Close() {
+	socket.lock.Lock()
+	defer socket.lock.Unlock()
+	if socket.conn != nil {
+		_ = socket.conn.Close()
+	}
+}
+
+// limitedConn A connection which reads the websocket frame headers that pass
+// through it and fails reading once a message is larger than the max size
+type limitedConn struct {
+	net.Conn
+	max       int64  // The maximum size of a message
+	header    []byte // The header bytes read so far of the next frame
+	remaining int64  // The payload bytes remaining for the current frame
+	size      int64  // The total payload size of the current message
+}
+
+// Read Reads from the connection checking the frames in the data read
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if scanErr := c.scan(b[:n]); scanErr != nil {
+			return 0, scanErr
+		}
+	}
+	return n, err
+}
+
+// scan Steps through the frame headers and payloads in the provided data
+// keeping track of the size of the current message
+func (c *limitedConn) scan(data []byte) error {
+	for len(data) > 0 {
+		if c.remaining > 0 { // Skip over the payload of the current frame
+			skip := int64(len(data))
+			if skip > c.remaining {
+				skip = c.remaining
+			}
+			c.remaining -= skip
+			data = data[skip:]
+			continue
+		}
+
+		c.header = append(c.header, data[0])
+		data = data[1:]
+		if len(c.header) < 2 {
+			continue
+		}
+		need := 2
+		switch c.header[1] & 0x7F { // Extended payload lengths
+		case 126:
+			need += 2
+		case 127:
+			need += 8
+		}
+		if c.header[1]&0x80 != 0 { // Masking key
+			need += 4
+		}
+		if len(c.header) < need { // Wait for the rest of the header
+			continue
+		}
+
+		length := int64(c.header[1] & 0x7F)
+		switch length {
+		case 126:
+			length = int64(binary.BigEndian.Uint16(c.header[2:4]))
+		case 127:
+			length = int64(binary.BigEndian.Uint64(c.header[2:10]))
+		}
+		if length < 0 || length > c.max { // Reject oversized frames before adding to avoid overflow
+			return ErrMessageTooLarge
+		}
+		opcode := c.header[0] & 0x0F
+		if opcode == 1 || opcode == 2 { // Text and binary frames start a new message
+			c.size = 0
+		}
+		if opcode < 8 { // Control frames aren't part of the message
+			c.size += length
+		}
+		if c.size > c.max {
+			return ErrMessageTooLarge
+		}
+		c.remaining = length
+		c.header = c.header[:0]
+	}
+	return nil
+}
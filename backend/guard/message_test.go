@@ -0,0 +1,103 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// frame Creates a websocket frame with the provided opcode and payload. The
+// payload is masked when masked is true like client frames are
+func frame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	var out bytes.Buffer
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	out.WriteByte(first)
+	mask := byte(0)
+	if masked {
+		mask = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		out.WriteByte(mask | byte(length))
+	case length <= 0xFFFF:
+		out.WriteByte(mask | 126)
+		_ = binary.Write(&out, binary.BigEndian, uint16(length))
+	default:
+		out.WriteByte(mask | 127)
+		_ = binary.Write(&out, binary.BigEndian, uint64(length))
+	}
+	if masked {
+		key := []byte{1, 2, 3, 4}
+		out.Write(key)
+		for i, b := range payload {
+			out.WriteByte(b ^ key[i%4])
+		}
+	} else {
+		out.Write(payload)
+	}
+	return out.Bytes()
+}
+
+// readerConn A connection which reads from the provided reader
+type readerConn struct {
+	net.Conn
+	io.Reader
+}
+
+func (c *readerConn) Read(b []byte) (int, error) { return c.Reader.Read(b) }
+
+// readAll Reads the data through a limitedConn with the provided max size in
+// chunks of the provided size and returns the error the read failed with
+func readAll(data []byte, max int64, chunk int) error {
+	conn := &limitedConn{Conn: &readerConn{Reader: bytes.NewReader(data)}, max: max}
+	buf := make([]byte, chunk)
+	for {
+		_, err := conn.Read(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestLimitedConn(t *testing.T) {
+	payload := func(n int) []byte { return bytes.Repeat([]byte{'a'}, n) }
+	join := func(frames ...[]byte) []byte { return bytes.Join(frames, nil) }
+	tests := []struct {
+		name string
+		data []byte
+		max  int64
+		ok   bool
+	}{
+		{"small masked", frame(true, 1, payload(10), true), 100, true},
+		{"small unmasked", frame(true, 2, payload(10), false), 100, true},
+		{"at the limit", frame(true, 1, payload(100), true), 100, true},
+		{"just over the limit", frame(true, 1, payload(101), true), 100, false},
+		{"16-bit length", frame(true, 1, payload(300), true), 300, true},
+		{"16-bit length over", frame(true, 1, payload(301), true), 300, false},
+		{"64-bit length", frame(true, 2, payload(70000), true), 70000, true},
+		{"64-bit length over", frame(true, 2, payload(70001), true), 70000, false},
+		{"fragmented", join(frame(false, 1, payload(60), true), frame(true, 0, payload(40), true)), 100, true},
+		{"fragmented over", join(frame(false, 1, payload(60), true), frame(true, 0, payload(41), true)), 100, false},
+		{"control frames between fragments", join(frame(false, 1, payload(60), true), frame(true, 9, payload(50), true), frame(true, 0, payload(40), true)), 100, true},
+		{"messages reset the size", join(frame(true, 1, payload(90), true), frame(true, 1, payload(90), true)), 100, true},
+		{"huge 64-bit length", []byte{0x82, 0xFF, 0x80, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}, 100, false},
+	}
+	for _, test := range tests {
+		for _, chunk := range []int{1, 3, 4096} { // Headers and payloads split across reads
+			err := readAll(test.data, test.max, chunk)
+			if test.ok && err != nil {
+				t.Errorf("%s (chunk %d): unexpected error %s", test.name, chunk, err)
+			} else if !test.ok && err != ErrMessageTooLarge {
+				t.Errorf("%s (chunk %d): expected ErrMessageTooLarge but got %v", test.name, chunk, err)
+			}
+		}
+	}
+}
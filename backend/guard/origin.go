@@ -0,0 +1,48 @@
+package guard
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AllowedOrigins The origins that are allowed to connect to the websocket. All
+// origins are allowed when this is empty. Origins can use a wildcard for the
+// subdomain (e.g. https://*.example.com) or be * to allow any origin
+var AllowedOrigins []string
+
+// IsOriginAllowed Checks whether the origin of the request is one of the
+// AllowedOrigins. Requests without an origin aren't from browsers so are allowed
+func IsOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(AllowedOrigins) == 0 || origin == "" {
+		return true
+	}
+	for _, allowed := range AllowedOrigins { // Iterate over the allowed origins
+		if allowed == "*" || strings.EqualFold(allowed, origin) || matchWildcard(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard Checks whether the origin matches an allowed origin with a
+// wildcard subdomain. The scheme and port must match exactly and the wildcard
+// only matches subdomains not the domain itself
+func matchWildcard(allowed string, origin string) bool {
+	if !strings.Contains(allowed, "://*.") {
+		return false
+	}
+	pattern, err := url.Parse(strings.Replace(allowed, "://*.", "://", 1))
+	if err != nil {
+		return false
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(pattern.Scheme, parsed.Scheme) || pattern.Port() != parsed.Port() {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(parsed.Hostname()), "."+strings.ToLower(pattern.Hostname()))
+}
@@ -0,0 +1,53 @@
+package guard
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies The networks of the reverse proxies in front of the server.
+// The X-Forwarded-For header is only used when the request comes from one of
+// these otherwise clients could pretend to be any IP
+var TrustedProxies []*net.IPNet
+
+// isTrusted Checks whether the provided IP belongs to a trusted proxy
+func isTrusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddress Retrieves the address of the client that made the request.
+// When the request comes through a trusted proxy the X-Forwarded-For header is
+// walked from the right skipping any other trusted proxies and the first IP
+// that isn't trusted is used (without a port). Otherwise this is the remote
+// address of the request
+func ClientAddress(r *http.Request) string {
+	address := r.RemoteAddr
+	if !isTrusted(AddressIP(address)) {
+		return address
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- { // Walk back towards the client
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(AddressIP(hop)) == nil { // Stop at anything that isn't an IP
+			break
+		}
+		address = AddressIP(hop)
+		if !isTrusted(address) { // The first untrusted hop is the client
+			break
+		}
+	}
+	return address
+}
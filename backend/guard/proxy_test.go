@@ -0,0 +1,61 @@
+package guard
+
+import (
+	"backend/config"
+	"net/http"
+	"testing"
+)
+
+func TestClientAddress(t *testing.T) {
+	proxies, err := config.ParseProxies([]string{"10.0.0.1", "172.16.0.0/12", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	TrustedProxies = proxies
+	t.Cleanup(func() { TrustedProxies = nil })
+
+	tests := []struct {
+		remote    string
+		forwarded []string
+		address   string
+		ip        string
+	}{
+		{"203.0.113.5:4000", nil, "203.0.113.5:4000", "203.0.113.5"},
+		{"203.0.113.5:4000", []string{"198.51.100.1"}, "203.0.113.5:4000", "203.0.113.5"}, // Untrusted peers can't forward
+		{"10.0.0.1:4000", []string{"198.51.100.1"}, "198.51.100.1", "198.51.100.1"},
+		{"[::1]:4000", []string{"198.51.100.1"}, "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:4000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1", "198.51.100.1"},          // Spoofed hops are ignored
+		{"10.0.0.1:4000", []string{"198.51.100.1, 172.16.4.2"}, "198.51.100.1", "198.51.100.1"},       // Chained trusted proxies
+		{"10.0.0.1:4000", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1", "198.51.100.1"},        // Repeated headers
+		{"10.0.0.1:4000", []string{"198.51.100.1:5555"}, "198.51.100.1", "198.51.100.1"},              // Ports are removed
+		{"10.0.0.1:4000", []string{"1.2.3.4, garbage, 198.51.100.1"}, "198.51.100.1", "198.51.100.1"}, // Stops at invalid hops
+		{"10.0.0.1:4000", []string{"garbage"}, "10.0.0.1:4000", "10.0.0.1"},
+		{"10.0.0.1:4000", nil, "10.0.0.1:4000", "10.0.0.1"},
+		{"10.0.0.1:4000", []string{"172.16.0.9"}, "172.16.0.9", "172.16.0.9"}, // Only proxies in the chain
+	}
+	for _, test := range tests {
+		r := &http.Request{RemoteAddr: test.remote, Header: http.Header{}}
+		for _, value := range test.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := ClientAddress(r); got != test.address {
+			t.Errorf("ClientAddress(%s, %v) = %s, want %s", test.remote, test.forwarded, got, test.address)
+		}
+		if got := ClientIP(r); got != test.ip {
+			t.Errorf("ClientIP(%s, %v) = %s, want %s", test.remote, test.forwarded, got, test.ip)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	for _, entries := range [][]string{{"10.0.0.1"}, {"10.0.0.0/8", "::1", "fd00::/8"}, nil} {
+		if _, err := config.ParseProxies(entries); err != nil {
+			t.Errorf("ParseProxies(%v) failed: %s", entries, err)
+		}
+	}
+	for _, entries := range [][]string{{"nginx"}, {"10.0.0.0/33"}, {""}} {
+		if _, err := config.ParseProxies(entries); err == nil {
+			t.Errorf("ParseProxies(%v) should fail", entries)
+		}
+	}
+}
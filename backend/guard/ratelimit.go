@@ -0,0 +1,194 @@
+package guard
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate A structure representing how many packets can be sent within a period.
+// The full count can be sent at once after which they refill over the period
+type Rate struct {
+	Count int           // The number of packets allowed
+	Per   time.Duration // The period the packets refill over
+}
+
+// Rate limits for packets. These are the defaults which can be changed by
+// the config using Configure
+var (
+	RateLimited   = true                              // Whether packets are rate limited
+	Rates         = map[int]Rate{}                    // The rates for specific packet ids
+	DefaultRate   = Rate{Count: 50, Per: time.Second} // The rate for packets without a specific rate
+	IPMultiplier  = 5                                 // How many times the rates are allowed for each IP
+	MaxViolations = 20                                // The throttled packets before a client is disconnected
+
+	ViolationDecay = 10 * time.Second // How long it takes for one violation to be forgiven
+)
+
+// IPSweepInterval How often the buckets and failed lookups of IPs that
+// haven't been seen recently are removed
+const IPSweepInterval = time.Minute
+
+// bucket A token bucket for a single packet id
+type bucket struct {
+	tokens float64   // The number of packets that can currently be sent
+	last   time.Time // The last time the tokens were refilled
+}
+
+// refill Refills the bucket for the time passed since it was last refilled
+func (b *bucket) refill(rate Rate, multiplier int, now time.Time) {
+	capacity := float64(rate.Count * multiplier)
+	if b.last.IsZero() { // New buckets start full
+		b.tokens = capacity
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() / rate.Per.Seconds() * capacity
+		if b.tokens > capacity {
+			b.tokens = capacity
+		}
+	}
+	b.last = now
+}
+
+// take Refills the bucket for the time passed then attempts to take a token
+func (b *bucket) take(rate Rate, multiplier int, now time.Time) bool {
+	b.refill(rate, multiplier, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ipBuckets A structure for the buckets shared by all connections from an IP
+type ipBuckets struct {
+	buckets map[int]*bucket // The buckets for each packet id
+	last    time.Time       // The last time a packet was sent from the IP
+}
+
+var (
+	ips        = map[string]*ipBuckets{} // The buckets for each IP
+	ipsLock    sync.Mutex                // A lock for the IP buckets
+	sweepStart sync.Once                 // Ensures the sweeping goroutine is only started once
+)
+
+// Limiter A structure which rate limits the packets of a single connection
+// using token buckets for each packet id for both the connection and its IP
+type Limiter struct {
+	IP         string          // The IP of the connection
+	Violations int             // The number of packets that have been throttled and not yet forgiven
+	buckets    map[int]*bucket // The buckets for each packet id
+	lock       sync.Mutex      // A lock for the buckets
+
+	decayed time.Time // The last time violations were forgiven
+}
+
+// NewLimiter Creates a new limiter for a connection from the provided IP
+func NewLimiter(ip string) *Limiter {
+	sweepStart.Do(func() { go sweepIPs() })
+	return &Limiter{IP: ip, buckets: map[int]*bucket{}}
+}
+
+// rateFor Retrieves the rate for the provided packet id
+func rateFor(id int) Rate {
+	if rate, exists := Rates[id]; exists {
+		return rate
+	}
+	return DefaultRate
+}
+
+// Allow Checks whether the connection is allowed to send the packet with the
+// provided id taking a token from both the connection and IP buckets. Tokens
+// are only taken when both buckets have one. Each packet that isn't allowed
+// is counted as a violation
+func (limiter *Limiter) Allow(id int) bool {
+	if !RateLimited {
+		return true
+	}
+	rate := rateFor(id)
+	now := time.Now()
+
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	b, exists := limiter.buckets[id]
+	if !exists {
+		b = &bucket{}
+		limiter.buckets[id] = b
+	}
+	b.refill(rate, 1, now)
+	// Only take from the IP once the connection has a token to take
+	allowed := b.tokens >= 1 && takeIP(limiter.IP, id, rate, now)
+	limiter.decay(now)
+	if allowed {
+		b.tokens--
+	} else {
+		limiter.Violations++
+	}
+	return allowed
+}
+
+// decay Forgives one violation for every ViolationDecay that has passed so
+// that clients are only disconnected for being throttled often. The limiter
+// lock must be held by the caller
+func (limiter *Limiter) decay(now time.Time) {
+	if limiter.Violations == 0 {
+		limiter.decayed = now
+		return
+	}
+	forgiven := int(now.Sub(limiter.decayed) / ViolationDecay)
+	if forgiven <= 0 {
+		return
+	}
+	limiter.decayed = limiter.decayed.Add(time.Duration(forgiven) * ViolationDecay)
+	limiter.Violations -= forgiven
+	if limiter.Violations < 0 {
+		limiter.Violations = 0
+	}
+}
+
+// Exceeded Checks whether the connection has been throttled enough times
+// that it should be disconnected
+func (limiter *Limiter) Exceeded() bool {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	return limiter.Violations >= MaxViolations
+}
+
+// takeIP Attempts to take a token from the bucket of the provided IP
+func takeIP(ip string, id int, rate Rate, now time.Time) bool {
+	ipsLock.Lock()
+	defer ipsLock.Unlock()
+	entry, exists := ips[ip]
+	if !exists {
+		entry = &ipBuckets{buckets: map[int]*bucket{}}
+		ips[ip] = entry
+	}
+	entry.last = now
+	b, exists := entry.buckets[id]
+	if !exists {
+		b = &bucket{}
+		entry.buckets[id] = b
+	}
+	return b.take(rate, IPMultiplier, now)
+}
+
+// sweepIPs Removes the buckets for IPs that haven't sent a packet for long
+// enough that all their buckets would be full again along with any expired
+// failed lookups
+func sweepIPs() {
+	for {
+		time.Sleep(IPSweepInterval)
+		sweepLookups()
+		longest := DefaultRate.Per
+		for _, rate := range Rates {
+			if rate.Per > longest {
+				longest = rate.Per
+			}
+		}
+		ipsLock.Lock()
+		for ip, entry := range ips {
+			if time.Since(entry.last) > longest {
+				delete(ips, ip)
+			}
+		}
+		ipsLock.Unlock()
+	}
+}
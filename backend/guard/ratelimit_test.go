@@ -0,0 +1,56 @@
+package guard
+
+import (
+	"testing"
+	"time"
+)
+
+// withRate sets the rate for every packet and the IP multiplier until the
+// test finishes
+func withRate(t *testing.T, rate Rate, multiplier int) {
+	rates, defaultRate, ipMultiplier := Rates, DefaultRate, IPMultiplier
+	Rates, DefaultRate, IPMultiplier = map[int]Rate{}, rate, multiplier
+	t.Cleanup(func() { Rates, DefaultRate, IPMultiplier = rates, defaultRate, ipMultiplier })
+}
+
+func TestAllowIPDeniedKeepsConnectionToken(t *testing.T) {
+	withRate(t, Rate{Count: 2, Per: time.Hour}, 1)
+	first, second := NewLimiter("192.0.2.10"), NewLimiter("192.0.2.10")
+	for i := 0; i < 2; i++ { // Use up the tokens of the IP
+		if !first.Allow(1) {
+			t.Fatalf("packet %d should be allowed", i)
+		}
+	}
+	if second.Allow(1) {
+		t.Fatal("the IP is out of tokens so the packet shouldn't be allowed")
+	}
+	if tokens := second.buckets[1].tokens; tokens != 2 {
+		t.Errorf("connection has %v tokens after the IP denied the packet, want 2", tokens)
+	}
+}
+
+func TestViolationsDecay(t *testing.T) {
+	withRate(t, Rate{Count: 1, Per: time.Hour}, 1)
+	limiter := NewLimiter("192.0.2.11")
+	limiter.Allow(1)
+	for i := 0; i < 3; i++ {
+		limiter.Allow(1)
+	}
+	if limiter.Violations != 3 {
+		t.Fatalf("got %d violations, want 3", limiter.Violations)
+	}
+
+	start := limiter.decayed
+	limiter.decay(start.Add(ViolationDecay / 2))
+	if limiter.Violations != 3 {
+		t.Errorf("got %d violations before a full decay, want 3", limiter.Violations)
+	}
+	limiter.decay(start.Add(2 * ViolationDecay))
+	if limiter.Violations != 1 {
+		t.Errorf("got %d violations after two decays, want 1", limiter.Violations)
+	}
+	limiter.decay(start.Add(10 * ViolationDecay))
+	if limiter.Violations != 0 {
+		t.Errorf("got %d violations after the decay, want 0", limiter.Violations)
+	}
+}
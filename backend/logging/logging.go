@@ -0,0 +1,195 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level The level of a log message represented as an 8-bit integer
+type Level = uint8
+
+// Enum for log levels
+const (
+	DebugLevel Level = iota // Verbose messages useful for debugging
+	InfoLevel               // General messages about what is happening
+	WarnLevel               // Messages about things that may be a problem
+	ErrorLevel              // Messages about things that have gone wrong
+)
+
+// LevelNames The names of each of the log levels used in the output
+var LevelNames = map[Level]string{
+	DebugLevel: "DEBUG",
+	InfoLevel:  "INFO",
+	WarnLevel:  "WARN",
+	ErrorLevel: "ERROR",
+}
+
+// Format The output format of the logger
+type Format = string
+
+// The different output formats
+const (
+	TextFormat Format = "text" // Human-readable single line output
+	JSONFormat Format = "json" // One JSON object per line
+)
+
+var (
+	lock                = sync.Mutex{} // A lock for writing to the output
+	output    io.Writer = os.Stderr    // The output to write log lines to
+	minLevel            = InfoLevel    // The minimum level of messages to write
+	format              = TextFormat   // The format to write messages in
+	timestamp           = time.RFC3339 // The format used for the timestamps
+)
+
+// Entry A structure representing the context of a log message. Every line
+// carries the event type, game id and player id along with any other fields
+type Entry struct {
+	Event  string                 // The type of event being logged
+	Game   string                 // The id of the game this event is for
+	Player string                 // The id of the player this event is for
+	Fields map[string]interface{} // Any other fields to include
+}
+
+// ParseLevel Parses the provided level name into a Level. Returns false if
+// the level name is not known
+func ParseLevel(name string) (Level, bool) {
+	for level, levelName := range LevelNames { // Iterate over the level names
+		if strings.EqualFold(levelName, name) || (strings.EqualFold(name, "warning") && level == WarnLevel) {
+			return level, true
+		}
+	}
+	return InfoLevel, false
+}
+
+// Configure Sets the minimum level and output format from their names. Unknown
+// values will return an error and leave the current configuration
+func Configure(levelName string, formatName string) error {
+	level, ok := ParseLevel(levelName)
+	if !ok {
+		return fmt.Errorf("unknown log level '%s'", levelName)
+	}
+	if formatName != TextFormat && formatName != JSONFormat {
+		return fmt.Errorf("unknown log format '%s'", formatName)
+	}
+	lock.Lock()
+	minLevel = level
+	format = formatName
+	lock.Unlock()
+	return nil
+}
+
+// SetOutput Sets the output that log lines are written to
+func SetOutput(w io.Writer) {
+	lock.Lock()
+	output = w
+	lock.Unlock()
+}
+
+// Event Creates a new log entry for the provided event type
+func Event(event string) *Entry {
+	return &Entry{Event: event}
+}
+
+// WithGame Sets the game id for the entry and returns the entry
+func (entry *Entry) WithGame(id string) *Entry {
+	entry.Game = id
+	return entry
+}
+
+// WithPlayer Sets the player id for the entry and returns the entry
+func (entry *Entry) WithPlayer(id string) *Entry {
+	entry.Player = id
+	return entry
+}
+
+// With Sets an extra field on the entry and returns the entry
+func (entry *Entry) With(key string, value interface{}) *Entry {
+	if entry.Fields == nil {
+		entry.Fields = map[string]interface{}{}
+	}
+	entry.Fields[key] = value
+	return entry
+}
+
+// Debug Logs the message at the debug level
+func (entry *Entry) Debug(message string, args ...interface{}) {
+	entry.Log(DebugLevel, message, args...)
+}
+
+// Info Logs the message at the info level
+func (entry *Entry) Info(message string, args ...interface{}) {
+	entry.Log(InfoLevel, message, args...)
+}
+
+// Warn Logs the message at the warn level
+func (entry *Entry) Warn(message string, args ...interface{}) {
+	entry.Log(WarnLevel, message, args...)
+}
+
+// Error Logs the message at the error level
+func (entry *Entry) Error(message string, args ...interface{}) {
+	entry.Log(ErrorLevel, message, args...)
+}
+
+// Fatal Logs the message at the error level and exits the application
+func (entry *Entry) Fatal(message string, args ...interface{}) {
+	entry.Log(ErrorLevel, message, args...)
+	os.Exit(1)
+}
+
+// Log Formats the message with the provided args and writes it along with
+// the entry context if the level is at least the minimum level
+func (entry *Entry) Log(level Level, message string, args ...interface{}) {
+	lock.Lock()
+	defer lock.Unlock()
+	if level < minLevel { // If the level is below the minimum level
+		return
+	}
+	if len(args) > 0 { // Only format the message when there are args
+		message = fmt.Sprintf(message, args...)
+	}
+	now := time.Now().Format(timestamp)
+	if format == JSONFormat {
+		line := map[string]interface{}{}
+		for key, value := range entry.Fields { // Copy the extra fields into the line
+			line[key] = value
+		}
+		line["time"] = now
+		line["level"] = strings.ToLower(LevelNames[level])
+		line["event"] = entry.Event
+		line["game"] = entry.Game
+		line["player"] = entry.Player
+		line["msg"] = message
+		encoded, err := json.Marshal(line)
+		if err != nil { // Fallback to just the message if the fields can't be encoded
+			encoded, _ = json.Marshal(map[string]string{"time": now, "level": "error", "msg": message})
+		}
+		_, _ = fmt.Fprintln(output, string(encoded))
+	} else {
+		builder := strings.Builder{}
+		builder.WriteString(fmt.Sprintf("%s %-5s [%s]", now, LevelNames[level], entry.Event))
+		if entry.Game != "" {
+			builder.WriteString(" game=" + entry.Game)
+		}
+		if entry.Player != "" {
+			builder.WriteString(" player=" + entry.Player)
+		}
+		keys := make([]string, 0, len(entry.Fields))
+		for key := range entry.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys { // Write the extra fields in a stable order
+			builder.WriteString(fmt.Sprintf(" %s=%q", key, fmt.Sprint(entry.Fields[key])))
+		}
+		builder.WriteString(" ")
+		builder.WriteString(message)
+		_, _ = fmt.Fprintln(output, builder.String())
+	}
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import "fmt"
+
+// Buckets for the different histograms
+var (
+	LatencyBuckets  = []float64{0.5, 1, 2, 3, 5, 7.5, 10, 15, 30}                     // Buckets for answer latency in seconds
+	DurationBuckets = []float64{60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 7200} // Buckets for game durations in seconds
+	PlayerBuckets   = []float64{0, 5, 10, 25, 50, 100, 250}                           // Buckets for the number of players in a game
+)
+
+// The metrics which are updated as things happen
+var (
+	GamesCreated        = NewCounter("quizler_games_created_total", "Total number of games created")
+	SocketsConnected    = NewGauge("quizler_sockets_connected", "Number of currently connected websockets")
+	ConnectionsRejected = NewCounter("quizler_connections_rejected_total", "Total number of websocket connections rejected by reason", "reason")
+	PacketsIn           = NewCounter("quizler_packets_in_total", "Total number of packets received from clients by packet id", "id")
+	PacketsThrottled    = NewCounter("quizler_packets_throttled_total", "Total number of packets dropped for exceeding the rate limit by packet id", "id")
+	PacketsOut          = NewCounter("quizler_packets_out_total", "Total number of packets sent to clients by packet id", "id")
+	Errors              = NewCounter("quizler_errors_total", "Total number of error packets sent to clients by error code", "code")
+	AnswerLatency       = NewHistogram("quizler_answer_latency_seconds", "Time taken by players to answer questions", LatencyBuckets)
+	GameDuration        = NewHistogram("quizler_game_duration_seconds", "Time from games being created until they are removed", DurationBuckets)
+)
+
+// PacketLabel Creates the label value used for the provided packet id
+func PacketLabel(id int) string {
+	return fmt.Sprintf("0x%02X", id)
+}
@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// Collector An interface implemented by each of the metric types which
+	// writes the metric in the Prometheus text exposition format
+	Collector interface {
+		Write(w io.Writer)
+	}
+
+	// Desc A structure describing a metric which is shared between the metric types
+	Desc struct {
+		Name   string   // The name of the metric
+		Help   string   // The help text describing the metric
+		Type   string   // The Prometheus type of the metric
+		Labels []string // The names of the labels for this metric
+	}
+
+	// Counter A metric which can only increase. Values are stored for each
+	// unique set of label values
+	Counter struct {
+		Desc
+		lock   sync.Mutex         // A lock for modifying the values
+		values map[string]float64 // A map of the joined label values to the value
+	}
+
+	// Gauge A metric which can increase and decrease
+	Gauge struct {
+		Counter
+	}
+
+	// GaugeFunc A gauge metric whose values are collected when the metrics
+	// are requested rather than being updated as things happen
+	GaugeFunc struct {
+		Desc
+		collect func() map[string]float64 // The function collecting the label value to value map
+	}
+
+	// Histogram A metric which counts observed values into buckets
+	Histogram struct {
+		Desc
+		buckets []float64                 // The upper bounds of the buckets
+		lock    sync.Mutex                // A lock for modifying the values
+		values  map[string]*histogramData // A map of the joined label values to the data
+	}
+
+	// histogramData The observed data for a histogram for a set of label values
+	histogramData struct {
+		counts []uint64 // The count for each of the buckets
+		sum    float64  // The sum of all the observed values
+		count  uint64   // The total number of observed values
+	}
+)
+
+// labelSeparator The separator used when joining label values into map keys
+const labelSeparator = "\xff"
+
+// registryLock A lock for modifying the registry
+var registryLock = sync.Mutex{}
+
+// registry All the registered metrics in the order they were registered
+var registry []Collector
+
+// Register Adds the provided collector to the registry so that it will be
+// included in the metrics output
+func Register(collector Collector) {
+	registryLock.Lock()
+	registry = append(registry, collector)
+	registryLock.Unlock()
+}
+
+// NewCounter Creates and registers a new counter metric
+func NewCounter(name string, help string, labels ...string) *Counter {
+	counter := &Counter{
+		Desc:   Desc{Name: name, Help: help, Type: "counter", Labels: labels},
+		values: map[string]float64{},
+	}
+	Register(counter)
+	return counter
+}
+
+// NewGauge Creates and registers a new gauge metric
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	gauge := &Gauge{Counter: Counter{
+		Desc:   Desc{Name: name, Help: help, Type: "gauge", Labels: labels},
+		values: map[string]float64{},
+	}}
+	Register(gauge)
+	return gauge
+}
+
+// NewGaugeFunc Creates and registers a new gauge metric which uses the collect
+// function to retrieve its values. The collect function returns a map of the
+// label value to the gauge value. For gauges without a label the key is empty
+func NewGaugeFunc(name string, help string, label string, collect func() map[string]float64) *GaugeFunc {
+	var labels []string
+	if label != "" {
+		labels = []string{label}
+	}
+	gauge := &GaugeFunc{
+		Desc:    Desc{Name: name, Help: help, Type: "gauge", Labels: labels},
+		collect: collect,
+	}
+	Register(gauge)
+	return gauge
+}
+
+// NewHistogram Creates and registers a new histogram metric with the provided
+// bucket upper bounds
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	histogram := &Histogram{
+		Desc:    Desc{Name: name, Help: help, Type: "histogram", Labels: labels},
+		buckets: buckets,
+		values:  map[string]*histogramData{},
+	}
+	Register(histogram)
+	return histogram
+}
+
+// Add Increases the counter for the label values by the provided value
+func (counter *Counter) Add(value float64, labels ...string) {
+	key := strings.Join(labels, labelSeparator)
+	counter.lock.Lock()
+	counter.values[key] += value
+	counter.lock.Unlock()
+}
+
+// Inc Increases the counter for the label values by one
+func (counter *Counter) Inc(labels ...string) {
+	counter.Add(1, labels...)
+}
+
+// Dec Decreases the gauge for the label values by one
+func (gauge *Gauge) Dec(labels ...string) {
+	gauge.Add(-1, labels...)
+}
+
+// Set Sets the gauge for the label values to the provided value
+func (gauge *Gauge) Set(value float64, labels ...string) {
+	key := strings.Join(labels, labelSeparator)
+	gauge.lock.Lock()
+	gauge.values[key] = value
+	gauge.lock.Unlock()
+}
+
+// Observe Adds the provided value to the histogram for the label values
+func (histogram *Histogram) Observe(value float64, labels ...string) {
+	key := strings.Join(labels, labelSeparator)
+	histogram.lock.Lock()
+	data, exists := histogram.values[key]
+	if !exists { // Create the data for this label set if it doesn't exist
+		data = &histogramData{counts: make([]uint64, len(histogram.buckets))}
+		histogram.values[key] = data
+	}
+	for i, bound := range histogram.buckets { // Iterate over the bucket bounds
+		if value <= bound {
+			data.counts[i]++
+		}
+	}
+	data.sum += value
+	data.count++
+	histogram.lock.Unlock()
+}
+
+// Write writes the counter values in the exposition format
+func (counter *Counter) Write(w io.Writer) {
+	counter.lock.Lock()
+	values := make(map[string]float64, len(counter.values))
+	for key, value := range counter.values { // Copy the values so the lock isn't held while writing
+		values[key] = value
+	}
+	counter.lock.Unlock()
+	counter.writeHeader(w)
+	writeValues(w, &counter.Desc, values)
+}
+
+// Write writes the collected gauge values in the exposition format
+func (gauge *GaugeFunc) Write(w io.Writer) {
+	values := gauge.collect()
+	gauge.writeHeader(w)
+	if len(gauge.Labels) == 0 {
+		_, _ = fmt.Fprintf(w, "%s %s\n", gauge.Name, formatValue(values[""]))
+		return
+	}
+	writeValues(w, &gauge.Desc, values)
+}
+
+// Write writes the histogram buckets, sum and count in the exposition format
+func (histogram *Histogram) Write(w io.Writer) {
+	histogram.writeHeader(w)
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	for _, key := range sortedKeys(histogram.values) {
+		data := histogram.values[key]
+		labels := formatLabels(histogram.Labels, key)
+		for i, bound := range histogram.buckets { // Iterate over the bucket bounds
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", histogram.Name, withLabel(labels, "le", formatValue(bound)), data.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", histogram.Name, withLabel(labels, "le", "+Inf"), data.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", histogram.Name, labels, formatValue(data.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", histogram.Name, labels, data.count)
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines for the metric
+func (desc *Desc) writeHeader(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", desc.Name, escape(desc.Help, false))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", desc.Name, desc.Type)
+}
+
+// writeValues writes each of the values with their labels sorted by label values
+func writeValues(w io.Writer, desc *Desc, values map[string]float64) {
+	for _, key := range sortedKeys(values) {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", desc.Name, formatLabels(desc.Labels, key), formatValue(values[key]))
+	}
+}
+
+// sortedKeys returns the keys of the provided map in sorted order so that the
+// output is stable between requests
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels formats the label names and joined label values as a label set
+// e.g. {id="0x01"} or an empty string if there are no labels
+func formatLabels(names []string, key string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	values := strings.Split(key, labelSeparator)
+	parts := make([]string, len(names))
+	for i, name := range names { // Iterate over the label names
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		parts[i] = fmt.Sprintf(`%s="%s"`, name, escape(value, true))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// withLabel adds an extra label to an already formatted label set
+func withLabel(labels string, name string, value string) string {
+	label := fmt.Sprintf(`%s="%s"`, name, value)
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+// formatValue formats a metric value as a string
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escape escapes the backslashes and newlines in the provided value along
+// with quotes when the value is a label value
+func escape(value string, quotes bool) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	if quotes {
+		value = strings.ReplaceAll(value, `"`, `\"`)
+	}
+	return value
+}
+
+// Handler creates a http handler which writes all the registered metrics. If
+// a token is provided requests must provide it as a bearer token
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := []byte(r.Header.Get("Authorization"))
+		if token != "" && subtle.ConstantTimeCompare(provided, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registryLock.Lock()
+		collectors := make([]Collector, len(registry))
+		copy(collectors, registry)
+		registryLock.Unlock()
+		for _, collector := range collectors { // Write each of the metrics
+			collector.Write(w)
+		}
+	})
+}
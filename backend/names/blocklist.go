@@ -0,0 +1,139 @@
+package names
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	blockedWords []string     // Words that aren't allowed as any of the words in names
+	blockedNames []string     // Words that aren't allowed as the whole name
+	blockedLock  sync.RWMutex // A lock for the blocked words
+)
+
+// LoadBlocklist Loads the blocked words from the provided file replacing any
+// that were loaded before. The file has one word per line and lines starting
+// with # are ignored. Words are blocked when they are one of the words of a
+// name unless they start with = in which case only names that are exactly
+// that word are blocked
+func LoadBlocklist(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var words, whole []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() { // Iterate over the lines of the file
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "=") { // Words that only block the whole name
+			if key := blockKey(line[1:]); key != "" {
+				whole = append(whole, key)
+			}
+		} else if key := blockKey(line); key != "" {
+			words = append(words, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	blockedLock.Lock()
+	blockedWords = words
+	blockedNames = whole
+	blockedLock.Unlock()
+	return nil
+}
+
+// blockKey Creates the key used to match names against the blocklist. This
+// is the skeleton of the name with common letter substitutions replaced and
+// everything other than letters removed so that spacing and punctuation can't
+// be used to get around the blocklist
+func blockKey(name string) string {
+	var out strings.Builder
+	for _, r := range Skeleton(name) {
+		if replacement, exists := leet[r]; exists {
+			r = replacement
+		}
+		if unicode.IsLetter(r) {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// blockTokens Splits the name into the keys of its words so that blocked
+// words only match whole words and innocent names like "Classic" aren't
+// blocked for containing one. Words are split by anything that isn't a letter
+// once substitutions are replaced, and separately where a lowercase letter is
+// followed by an uppercase one so "BigIdiot" is checked as "big" and "idiot".
+// Runs of single letters like "i d i o t" and all the letters of the name
+// joined together are checked as words too
+func blockTokens(name string) []string {
+	var tokens []string
+	var whole, word, part, singles strings.Builder
+	letters, lower := 0, false
+	endWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		tokens = append(tokens, word.String(), part.String())
+		if letters == 1 { // Collect single letters which may be a word that was spaced out
+			singles.WriteString(word.String())
+		} else {
+			tokens = append(tokens, singles.String())
+			singles.Reset()
+		}
+		word.Reset()
+		part.Reset()
+		letters, lower = 0, false
+	}
+	for _, r := range Clean(name) {
+		if unicode.In(r, unicode.Mn, unicode.Me) { // Marks are part of the letter before them
+			continue
+		}
+		key := blockKey(string(r))
+		if key == "" { // Anything that isn't a letter separates words
+			endWord()
+			continue
+		}
+		if lower && unicode.IsUpper(r) { // A new part of a word in camel case
+			tokens = append(tokens, part.String())
+			part.Reset()
+		}
+		lower = unicode.IsLower(r)
+		whole.WriteString(key)
+		word.WriteString(key)
+		part.WriteString(key)
+		letters++
+	}
+	endWord()
+	return append(tokens, singles.String(), whole.String())
+}
+
+// IsBlocked Checks whether any of the words of the name are blocked or the
+// whole name is blocked
+func IsBlocked(name string) bool {
+	key := blockKey(name)
+	tokens := blockTokens(name)
+	blockedLock.RLock()
+	defer blockedLock.RUnlock()
+	for _, word := range blockedWords {
+		for _, token := range tokens {
+			if token == word {
+				return true
+			}
+		}
+	}
+	for _, word := range blockedNames {
+		if key == word {
+			return true
+		}
+	}
+	return false
+}
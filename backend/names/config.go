@@ -0,0 +1,14 @@
+package names
+
+import "backend/config"
+
+// Configure Sets the name limits from the provided config and loads the
+// blocklist file if one was provided
+func Configure(config *config.Config) error {
+	MinLength = config.Names.MinLength
+	MaxLength = config.Names.MaxLength
+	if config.Names.BlocklistFile != "" {
+		return LoadBlocklist(config.Names.BlocklistFile)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package names
+
+// confusables Characters that are easily confused with a lowercase Latin
+// character mapped to that character. This covers the common lookalikes from
+// the Cyrillic and Greek alphabets along with digits and symbols. Characters
+// are mapped before being converted to lowercase. I, 1 and | are mapped to l
+// and Skeleton also maps i to l so that all of them match
+var confusables = map[rune]rune{
+	// Latin and digits
+	'I': 'l', '1': 'l', '|': 'l', '0': 'o', 'ı': 'i', 'ſ': 'f',
+
+	// Cyrillic
+	'А': 'a', 'а': 'a', 'В': 'b', 'в': 'b', 'Ь': 'b', 'ь': 'b', 'С': 'c', 'с': 'c',
+	'Ԁ': 'd', 'ԁ': 'd', 'Е': 'e', 'е': 'e', 'Ё': 'e', 'ё': 'e', 'Һ': 'h', 'һ': 'h',
+	'Н': 'h', 'н': 'h', 'І': 'l', 'і': 'i', 'Ї': 'i', 'ї': 'i', 'Ј': 'j', 'ј': 'j',
+	'К': 'k', 'к': 'k', 'Ӏ': 'l', 'ӏ': 'l', 'М': 'm', 'м': 'm', 'О': 'o', 'о': 'o',
+	'Р': 'p', 'р': 'p', 'Ԛ': 'q', 'ԛ': 'q', 'Ѕ': 's', 'ѕ': 's', 'Т': 't', 'т': 't',
+	'Ս': 'u', 'ս': 'u', 'Ѵ': 'v', 'ѵ': 'v', 'Ԝ': 'w', 'ԝ': 'w', 'Х': 'x', 'х': 'x',
+	'У': 'y', 'у': 'y', 'Ү': 'y', 'ү': 'y',
+
+	// Greek
+	'Α': 'a', 'α': 'a', 'Β': 'b', 'β': 'b', 'Ε': 'e', 'ε': 'e', 'Ζ': 'z', 'Η': 'h',
+	'Ι': 'l', 'ι': 'i', 'Κ': 'k', 'κ': 'k', 'Μ': 'm', 'Ν': 'n', 'ν': 'v', 'Ο': 'o',
+	'ο': 'o', 'Ρ': 'p', 'ρ': 'p', 'Τ': 't', 'τ': 't', 'Υ': 'y', 'υ': 'u', 'Χ': 'x',
+	'χ': 'x', 'γ': 'y', 'ω': 'w',
+}
+
+// leet Digits and symbols commonly used in place of letters to get around
+// word filters. These are only used when checking the blocklist so that names
+// like "Player3" don't collide with "Playere". These are applied to the
+// skeleton so ! is mapped to l which is the skeleton of i
+var leet = map[rune]rune{
+	'3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'l', '+': 't',
+}
@@ -0,0 +1,106 @@
+package names
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits for player names. These are the defaults which can be changed by
+// the config using Configure
+var (
+	MinLength = 1  // The minimum number of characters in a name
+	MaxLength = 16 // The maximum number of characters in a name
+)
+
+// MaxMarks The maximum number of combining marks allowed on a single
+// character which stops names from stacking marks over other names
+const MaxMarks = 2
+
+// Clean Normalises the provided name by removing invalid UTF-8, applying NFKC
+// normalisation (which also converts fullwidth characters to their ASCII form),
+// removing control, formatting and private use characters (e.g. zero width
+// spaces), collapsing runs of whitespace into a single space and limiting the
+// combining marks on each character
+func Clean(name string) string {
+	name = norm.NFKC.String(strings.ToValidUTF8(name, ""))
+	var out strings.Builder
+	marks := 0
+	space := false
+	for _, r := range name { // Iterate over the characters of the name
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case unicode.IsControl(r) || unicode.In(r, unicode.Cf, unicode.Co, unicode.Cs) || r == utf8.RuneError:
+			continue
+		case unicode.In(r, unicode.Mn, unicode.Me):
+			marks++
+			if marks > MaxMarks { // Drop marks stacked past the limit
+				continue
+			}
+		default:
+			marks = 0
+		}
+		if space && out.Len() > 0 { // Only keep spaces between other characters
+			out.WriteRune(' ')
+		}
+		space = false
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// Check Cleans the provided name and checks that it follows the name policy.
+// Returns the cleaned name or an error describing why the name isn't allowed
+func Check(name string) (string, error) {
+	name = Clean(name)
+	length := utf8.RuneCountInString(name)
+	if length < MinLength {
+		return "", fmt.Errorf("Names must be at least %d characters long", MinLength)
+	}
+	if length > MaxLength {
+		return "", fmt.Errorf("Names can't be longer than %d characters", MaxLength)
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return "", errors.New("Names must contain a letter or number")
+	}
+	if IsBlocked(name) {
+		return "", errors.New("That name isn't allowed")
+	}
+	return name, nil
+}
+
+// Skeleton Creates the skeleton of the provided name which is the same for
+// names that look alike. The name is decomposed so that accented characters
+// match however they were written and the combining marks are removed,
+// characters that are easily confused are replaced with the character they
+// look like and the name is converted to lowercase so that "Admin" and
+// "Аdmin" (Cyrillic A) match. I, i, 1, | and l all become l so that "ADMIN",
+// "Admin" and "Adm1n" match too
+func Skeleton(name string) string {
+	var out strings.Builder
+	for _, r := range norm.NFD.String(Clean(name)) {
+		if unicode.In(r, unicode.Mn, unicode.Me) { // Marks don't change how similar names look
+			continue
+		}
+		if replacement, exists := confusables[r]; exists {
+			r = replacement
+		} else {
+			r = unicode.ToLower(r)
+		}
+		if r == 'i' { // Uppercase I looks like l so i and l share the same skeleton
+			r = 'l'
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// Collides Checks whether the two names look alike
+func Collides(a string, b string) bool {
+	return Skeleton(a) == Skeleton(b)
+}
@@ -0,0 +1,118 @@
+package names
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadTestBlocklist loads a blocklist with the provided lines for the test
+func loadTestBlocklist(t *testing.T, lines string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadBlocklist(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		blockedLock.Lock()
+		blockedWords, blockedNames = nil, nil
+		blockedLock.Unlock()
+	})
+}
+
+func TestIsBlocked(t *testing.T) {
+	loadTestBlocklist(t, "# comment\nshit\nidiot\nass\n=admin\n")
+	tests := []struct {
+		name    string
+		blocked bool
+	}{
+		{"shit", true},
+		{"SHIT", true},
+		{"ShIt", true},
+		{"sh1t", true},
+		{"sh!t", true},
+		{"$h|t", true},
+		{"s h i t", true},
+		{"idiot", true},
+		{"IDIOT", true},
+		{"1d1ot", true},
+		{"Id10t", true},
+		{"ldlot", true},
+		{"іdіot", true}, // Cyrillic і
+		{"ｉｄｉｏｔ", true}, // Fullwidth
+		{"admin", true},
+		{"ADMIN", true},
+		{"admins", false},
+		{"notadmin", false},
+		{"bob", false},
+		{"Shiitake", false},
+		{"Player3", false},
+		{"Big Idiot", true},
+		{"big_idiot", true},
+		{"BigIdiot", true},
+		{"Big Ass", true},
+		{"a s s", true},
+		{"@$$", true},
+		{"Classic", false}, // Innocent names that contain a blocked word
+		{"Bass Player", false},
+		{"Assassin", false},
+		{"Passion", false},
+	}
+	for _, test := range tests {
+		if got := IsBlocked(test.name); got != test.blocked {
+			t.Errorf("IsBlocked(%q) = %v, want %v", test.name, got, test.blocked)
+		}
+	}
+}
+
+func TestCollides(t *testing.T) {
+	nfc := "Jos\u00e9"  // é as a single character
+	nfd := "Jose\u0301" // e followed by a combining acute accent
+	tests := []struct {
+		a, b    string
+		collide bool
+	}{
+		{nfc, nfd, true},
+		{nfc, "Jose", true},
+		{nfd, "Jose", true},
+		{"Admin", "admin", true},
+		{"Admin", "Аdmin", true}, // Cyrillic А
+		{"Bill", "BiII", true},
+		{"Ｂｏｂ", "Bob", true},       // Fullwidth
+		{"Bo\u200bb", "Bob", true}, // Zero width space
+		{"ﬁsh", "fish", true},      // Ligature
+		{"Bob", "Rob", false},
+		{"Player3", "Playere", false},
+	}
+	for _, test := range tests {
+		if got := Collides(test.a, test.b); got != test.collide {
+			t.Errorf("Collides(%q, %q) = %v, want %v", test.a, test.b, got, test.collide)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	loadTestBlocklist(t, "idiot\n")
+	tests := []struct {
+		name  string
+		clean string
+		valid bool
+	}{
+		{"  Bob   Smith ", "Bob Smith", true},
+		{"José", "José", true},
+		{"Ｂｏｂ", "Bob", true},
+		{"IDIOT", "", false},
+		{"\u200b", "", false},
+		{"!!!", "", false},
+		{"abcdefghijklmnopq", "", false},
+	}
+	for _, test := range tests {
+		clean, err := Check(test.name)
+		if (err == nil) != test.valid || clean != test.clean {
+			t.Errorf("Check(%q) = %q, %v, want %q valid=%v", test.name, clean, err, test.clean, test.valid)
+		}
+	}
+}
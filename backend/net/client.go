@@ -13,6 +13,16 @@ const (
 	CStateChange          = 0x04
 	CAnswer               = 0x05
 	CKick                 = 0x06
+	CResume               = 0x07
+	CSetPassword          = 0x08
+	CApprove              = 0x09
+	CRename               = 0x0A
+	CSpectate             = 0x0B
+	CPresent              = 0x0C
+	CSetTeam              = 0x0D
+	CPromote              = 0x0E
+	CTransferHost         = 0x0F
+	CUnban                = 0x10
 )
 
 type StateChangeId = uint8
@@ -21,20 +31,48 @@ const (
 	CDisconnect StateChangeId = iota
 	CStart
 	CSkip
+	CPause
+	CUnpause
 )
 
 // Different types for client packets
 type (
 
 	// KickData A structure representing the data a client will send to kick a player
+	// or to ban them from the game
 	KickData struct {
-		Id string `json:"id"` // The id of the player to kick
+		Id    string `json:"id"`               // The id of the player to kick
+		Ban   bool   `json:"ban,omitempty"`    // Whether to ban the player from rejoining
+		BanIP bool   `json:"ban_ip,omitempty"` // Whether to also ban the IP of the player
+	}
+
+	// UnbanData A structure representing the host lifting a ban
+	UnbanData struct {
+		Id string `json:"id"` // The id of the ban to lift
 	}
 
 	// CreateGameData A structure representing the data a client will send to create a game
 	CreateGameData struct {
-		Title     string               `json:"title"`     // The title of the game
-		Questions []tools.QuestionData `json:"questions"` // The questions to include in the game
+		Title       string               `json:"title"`                  // The title of the game
+		Questions   []tools.QuestionData `json:"questions"`              // The questions to include in the game
+		Password    string               `json:"password,omitempty"`     // The password required to join the game
+		Approval    bool                 `json:"approval,omitempty"`     // Whether the host must approve players before they join
+		LateJoins   bool                 `json:"late_joins,omitempty"`   // Whether players can join after the game has started
+		LateMinimum bool                 `json:"late_minimum,omitempty"` // Whether late joiners start with the lowest score instead of zero
+		Teams       []string             `json:"teams,omitempty"`        // The names of the teams for team games
+		TeamScoring string               `json:"team_scoring,omitempty"` // How team scores are combined (sum, average or best)
+		Consensus   bool                 `json:"consensus,omitempty"`    // Whether only the first answer from each team counts
+		TeamPick    bool                 `json:"team_pick,omitempty"`    // Whether players pick their own team instead of being assigned one
+
+		SelfPaced bool  `json:"self_paced,omitempty"` // Whether players answer at their own pace between the open and close times
+		Opens     int64 `json:"opens,omitempty"`      // The unix time in milliseconds a self-paced game opens (defaults to now)
+		Closes    int64 `json:"closes,omitempty"`     // The unix time in milliseconds a self-paced game closes
+
+		ShuffleQuestions bool `json:"shuffle_questions,omitempty"` // Whether the questions are served in a random order
+		ShuffleAnswers   bool `json:"shuffle_answers,omitempty"`   // Whether each player is shown the answers in their own order
+
+		Pools map[string]int `json:"pools,omitempty"` // The number of questions to draw from each question pool
+		Seed  *int64         `json:"seed,omitempty"`  // The seed for drawing and shuffling the questions (random when missing)
 	}
 
 	// CheckNameTakenData A structure representing a client checking the server for if a name
@@ -53,8 +91,10 @@ type (
 	// RequestJoinData A structure representing a client requesting to join a game with the
 	// provided Id using the provided Name
 	RequestJoinData struct {
-		Id   string `json:"id"`   // The id of the game (game code)
-		Name string `json:"name"` // The name to join the game with
+		Id       string `json:"id"`                 // The id of the game (game code)
+		Name     string `json:"name"`               // The name to join the game with
+		Password string `json:"password,omitempty"` // The password for games that require one
+		Team     *int   `json:"team,omitempty"`     // The team picked by the player in team games
 	}
 
 	// StateChangeData A structure representing a client requesting state change
@@ -62,6 +102,70 @@ type (
 		State StateChangeId `json:"state"` // The state to update
 	}
 
+	// ResumeData A structure representing a client resuming a previous session in a
+	// game using the session token it was given when it joined
+	ResumeData struct {
+		Id    string `json:"id"`    // The id of the game (game code)
+		Token string `json:"token"` // The session token given when joining
+	}
+
+	// SetPasswordData A structure representing the host changing the password
+	// required to join their game. An empty password makes the game public
+	SetPasswordData struct {
+		Password string `json:"password"` // The new password
+	}
+
+	// ApproveData A structure representing the host approving or denying a player
+	// waiting to join their game. All applies the decision to every pending player
+	ApproveData struct {
+		Id      string `json:"id"`            // The id of the pending player
+		Approve bool   `json:"approve"`       // Whether the player is approved or denied
+		All     bool   `json:"all,omitempty"` // Whether to apply to all pending players
+	}
+
+	// RenameData A structure representing the host changing the name of a player
+	RenameData struct {
+		Id   string `json:"id"`   // The id of the player to rename
+		Name string `json:"name"` // The new name for the player
+	}
+
+	// SpectateData A structure representing a client asking to watch a game as a
+	// spectator without playing
+	SpectateData struct {
+		Id       string `json:"id"`                 // The id of the game (game code)
+		Password string `json:"password,omitempty"` // The password for games that require one
+	}
+
+	// PresentData A structure representing a client asking to attach to a game
+	// as the presenter display using the presenter token given to the host
+	PresentData struct {
+		Id    string `json:"id"`    // The id of the game (game code)
+		Token string `json:"token"` // The presenter token of the game
+	}
+
+	// SetTeamData A structure representing a request to change teams. The host
+	// can move any player or balance all the teams, players can only pick their
+	// own team before the game starts
+	SetTeamData struct {
+		Id      string `json:"id"`                // The id of the player to move (host only)
+		Team    int    `json:"team"`              // The index of the team to move to
+		Balance bool   `json:"balance,omitempty"` // Whether to balance all the teams instead (host only)
+	}
+
+	// PromoteData A structure representing the host promoting a player to co-host
+	// with the provided permissions or demoting a co-host
+	PromoteData struct {
+		Id          string   `json:"id"`                    // The id of the player or co-host
+		Permissions []string `json:"permissions,omitempty"` // The control permissions to give the co-host
+		Demote      bool     `json:"demote,omitempty"`      // Whether to remove the co-host instead
+	}
+
+	// TransferHostData A structure representing the host handing ownership of the
+	// game over to one of the co-hosts
+	TransferHostData struct {
+		Id string `json:"id"` // The id of the co-host to make the host
+	}
+
 	// AnswerData A structure representing a client answering a question with the index
 	AnswerData struct {
 		Id tools.AnswerIndex `json:"id"` // The index of the answer
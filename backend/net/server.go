@@ -1,6 +1,7 @@
 package net
 
 import (
+	"backend/metrics"
 	"backend/tools"
 	. "github.com/jacobtread/gowsps"
 	"time"
@@ -18,8 +19,31 @@ const (
 	SQuestion            = 0x07
 	SAnswerResult        = 0x08
 	SScores              = 0x09
+	SResults             = 0x0A
+	SShutdown            = 0x0B
+	SJoinFailed          = 0x0C
+	SPendingPlayer       = 0x0D
+	SSpectating          = 0x0E
+	SPresenterToken      = 0x0F
+	SAnswerCount         = 0x10
+	STeams               = 0x11
+	SPlayerTeam          = 0x12
+	STeamScores          = 0x13
+	SHostRole            = 0x14
+	SCoHost              = 0x15
+	SPaused              = 0x16
+	SBanned              = 0x17
+	SAssignment          = 0x18
+	SSpectators          = 0x19
 )
 
+// Send sends the provided packet over the connection and records it in
+// the outgoing packet metrics
+func Send(conn *Connection, packet Packet) {
+	metrics.PacketsOut.Inc(metrics.PacketLabel(packet.Id))
+	conn.Send(packet)
+}
+
 // DisconnectPacket creates a new disconnect packet with the provided reason
 func DisconnectPacket(reason string) Packet {
 	return Packet{Id: SDisconnect, Data: struct {
@@ -33,13 +57,34 @@ const (
 	AddMode    PlayerDataMode = iota // Add the player to player lists
 	RemoveMode                       // Remove the player from player lists
 	SelfMode                         // Set this as the player for whoever this is sent to
+	RenameMode                       // Change the name of the player
 )
 
-// ErrorPacket creates a new error packet with the provided cause
-func ErrorPacket(cause string) Packet {
+// ErrorCode A fixed code for the kind of error in an error packet. The error
+// metrics are labelled with the code as the causes can contain client input
+type ErrorCode = string
+
+const (
+	CodeRateLimited ErrorCode = "rate_limited" // The client is sending packets too fast
+	CodeLockedOut   ErrorCode = "locked_out"   // The client tried too many game codes or passwords
+	CodeUnavailable ErrorCode = "unavailable"  // The server isn't accepting new games
+	CodeInvalid     ErrorCode = "invalid"      // The data sent by the client isn't allowed
+	CodeNotFound    ErrorCode = "not_found"    // The game, player, team, ban or co-host doesn't exist
+	CodeNotAllowed  ErrorCode = "not_allowed"  // The client isn't allowed to do that
+	CodePassword    ErrorCode = "password"     // The game password is missing or incorrect
+	CodeBanned      ErrorCode = "banned"       // The client is banned from the game
+	CodeFull        ErrorCode = "full"         // The game has no room for more players or spectators
+	CodeNameTaken   ErrorCode = "name_taken"   // The name is already in use
+	CodeState       ErrorCode = "state"        // The game isn't in a state that allows that
+)
+
+// ErrorPacket creates a new error packet with the provided code and cause
+func ErrorPacket(code ErrorCode, cause string) Packet {
+	metrics.Errors.Inc(code) // Record the error in the error metrics
 	return Packet{Id: SError, Data: struct {
-		Cause string `json:"cause"`
-	}{Cause: cause}}
+		Cause string    `json:"cause"`
+		Code  ErrorCode `json:"code"`
+	}{Cause: cause, Code: code}}
 }
 
 // PlayerDataPacket creates a new player data packet with the provided id and name
@@ -51,13 +96,27 @@ func PlayerDataPacket(id string, name string, mode PlayerDataMode) Packet {
 	}{Id: id, Name: name, Mode: mode}}
 }
 
-// JoinGamePacket creates a new join game data packet with the provided values
-func JoinGamePacket(owner bool, id string, title string) Packet {
+// PendingPlayerPacket creates a new pending player packet for a player waiting
+// to be approved by the host. The host is sent AddMode when a player asks to join
+// and RemoveMode once they are approved, denied or leave. The waiting player is
+// sent SelfMode
+func PendingPlayerPacket(id string, name string, mode PlayerDataMode) Packet {
+	return Packet{Id: SPendingPlayer, Data: struct {
+		Id   string         `json:"id"`   // The id of the pending player
+		Name string         `json:"name"` // The name of the pending player
+		Mode PlayerDataMode `json:"mode"` // Whether the player was added or removed
+	}{Id: id, Name: name, Mode: mode}}
+}
+
+// JoinGamePacket creates a new join game data packet with the provided values. The
+// token is the session token which can be used to resume the session later
+func JoinGamePacket(owner bool, id string, title string, token string) Packet {
 	return Packet{Id: SJoinedGame, Data: struct {
 		Owner bool   `json:"owner"` // Whether the player is the host/owner of the quiz
 		Id    string `json:"id"`    // The id of the joined game
 		Title string `json:"title"` // The title of the joined game
-	}{Id: id, Title: title, Owner: owner}}
+		Token string `json:"token"` // The session token for resuming
+	}{Id: id, Title: title, Owner: owner, Token: token}}
 }
 
 // NameTakenResultPacket creates a new name taken result packet with the provided result
@@ -93,12 +152,25 @@ func QuestionPacket(data tools.QuestionData) Packet {
 	}{Image: data.Image, Question: data.Question, Answers: data.Answers}}
 }
 
+// HostQuestionPacket creates a new question packet for the host which also
+// carries the private host notes for the question
+func HostQuestionPacket(data tools.QuestionData) Packet {
+	return Packet{Id: SQuestion, Data: struct {
+		Image    string   `json:"image,omitempty"`
+		Question string   `json:"question"`
+		Answers  []string `json:"answers"`
+		Notes    string   `json:"notes,omitempty"` // The private notes for the host
+	}{Image: data.Image, Question: data.Question, Answers: data.Answers, Notes: data.Notes}}
+}
+
 // AnswerResultPacket creates a new answer result packet which informs the client
-// whether the answer they chose was correct after marking
-func AnswerResultPacket(result bool) Packet {
+// whether the answer they chose was correct after marking along with the
+// explanation for the question if it has one
+func AnswerResultPacket(result bool, explanation string) Packet {
 	return Packet{Id: SAnswerResult, Data: struct {
-		Result bool `json:"result"`
-	}{Result: result}}
+		Result      bool   `json:"result"`
+		Explanation string `json:"explanation,omitempty"` // Why the correct answer is correct
+	}{Result: result, Explanation: explanation}}
 }
 
 // ScoresPacket creates a new score packet which contains the scores of all the
@@ -108,3 +180,141 @@ func ScoresPacket(data tools.ScoreMap) Packet {
 		Scores tools.ScoreMap `json:"scores"`
 	}{Scores: data}}
 }
+
+// ResultsPacket creates a new results packet which contains the token that the
+// host can use to download the results of the game once it has ended
+func ResultsPacket(token string) Packet {
+	return Packet{Id: SResults, Data: struct {
+		Token string `json:"token"`
+	}{Token: token}}
+}
+
+// ShutdownPacket creates a new shutdown packet which warns the client that the
+// server is shutting down and the game will be ended after the remaining time
+func ShutdownPacket(remaining time.Duration) Packet {
+	return Packet{Id: SShutdown, Data: struct {
+		Remaining int64 `json:"remaining"`
+	}{Remaining: remaining.Milliseconds()}}
+}
+
+// JoinFailedPacket creates a new join failed packet which tells the host that
+// someone tried to join their game with the wrong password
+func JoinFailedPacket(name string, attempts int) Packet {
+	return Packet{Id: SJoinFailed, Data: struct {
+		Name     string `json:"name"`     // The name the player tried to join with
+		Attempts int    `json:"attempts"` // The total failed attempts for the game
+	}{Name: name, Attempts: attempts}}
+}
+
+// SpectatingPacket creates a new spectating packet which tells the client they
+// are now watching the game with the provided id and title as a spectator or
+// as the presenter display
+func SpectatingPacket(id string, title string, presenter bool) Packet {
+	return Packet{Id: SSpectating, Data: struct {
+		Id        string `json:"id"`        // The id of the game being watched
+		Title     string `json:"title"`     // The title of the game being watched
+		Presenter bool   `json:"presenter"` // Whether the client is the presenter display
+	}{Id: id, Title: title, Presenter: presenter}}
+}
+
+// PresenterTokenPacket creates a new presenter token packet which gives the
+// host the token used to attach a presenter display to their game
+func PresenterTokenPacket(token string) Packet {
+	return Packet{Id: SPresenterToken, Data: struct {
+		Token string `json:"token"`
+	}{Token: token}}
+}
+
+// AnswerCountPacket creates a new answer count packet which tells the host and
+// presenter how many of the players have answered the current question
+func AnswerCountPacket(answered int, total int) Packet {
+	return Packet{Id: SAnswerCount, Data: struct {
+		Answered int `json:"answered"` // The number of players that have answered
+		Total    int `json:"total"`    // The number of players in the game
+	}{Answered: answered, Total: total}}
+}
+
+// TeamsPacket creates a new teams packet which tells the client the names of
+// the teams in a team game and how the team is scored
+func TeamsPacket(teams []string, scoring string, consensus bool, pick bool) Packet {
+	return Packet{Id: STeams, Data: struct {
+		Teams     []string `json:"teams"`     // The names of the teams
+		Scoring   string   `json:"scoring"`   // How the player scores are combined (sum, average or best)
+		Consensus bool     `json:"consensus"` // Whether only the first answer from each team counts
+		Pick      bool     `json:"pick"`      // Whether players can pick their own team
+	}{Teams: teams, Scoring: scoring, Consensus: consensus, Pick: pick}}
+}
+
+// PlayerTeamPacket creates a new player team packet which tells the client
+// which team the player with the provided id is in
+func PlayerTeamPacket(id string, team int) Packet {
+	return Packet{Id: SPlayerTeam, Data: struct {
+		Id   string `json:"id"`   // The id of the player
+		Team int    `json:"team"` // The index of the team the player is in
+	}{Id: id, Team: team}}
+}
+
+// TeamScoresPacket creates a new team scores packet containing the score of
+// each team in the same order as the teams
+func TeamScoresPacket(scores []uint32) Packet {
+	return Packet{Id: STeamScores, Data: struct {
+		Scores []uint32 `json:"scores"`
+	}{Scores: scores}}
+}
+
+// HostRolePacket creates a new host role packet which tells the client whether
+// they are the host (owner) of the game or a co-host with the provided
+// control permissions
+func HostRolePacket(owner bool, permissions []string) Packet {
+	return Packet{Id: SHostRole, Data: struct {
+		Owner       bool     `json:"owner"`       // Whether the client is the host of the game
+		Permissions []string `json:"permissions"` // The control permissions of the client
+	}{Owner: owner, Permissions: permissions}}
+}
+
+// CoHostPacket creates a new co-host packet which tells the host and co-hosts
+// that a co-host was added (AddMode) or removed (RemoveMode)
+func CoHostPacket(id string, name string, mode PlayerDataMode) Packet {
+	return Packet{Id: SCoHost, Data: struct {
+		Id   string         `json:"id"`   // The id of the co-host
+		Name string         `json:"name"` // The name of the co-host
+		Mode PlayerDataMode `json:"mode"` // Whether the co-host was added or removed
+	}{Id: id, Name: name, Mode: mode}}
+}
+
+// PausedPacket creates a new paused packet which tells the client whether
+// the game has been paused by the host
+func PausedPacket(paused bool) Packet {
+	return Packet{Id: SPaused, Data: struct {
+		Paused bool `json:"paused"`
+	}{Paused: paused}}
+}
+
+// BannedPacket creates a new banned packet which tells the host and co-hosts
+// that a player was banned (AddMode) or that their ban was lifted (RemoveMode)
+func BannedPacket(id string, name string, mode PlayerDataMode) Packet {
+	return Packet{Id: SBanned, Data: struct {
+		Id   string         `json:"id"`   // The id of the ban
+		Name string         `json:"name"` // The name of the banned player
+		Mode PlayerDataMode `json:"mode"` // Whether the ban was added or lifted
+	}{Id: id, Name: name, Mode: mode}}
+}
+
+// AssignmentPacket creates a new assignment packet which tells players and
+// hosts the times that a self-paced game opens and closes. The host is also
+// given the token for downloading the results which is empty for players
+func AssignmentPacket(opens time.Time, closes time.Time, results string) Packet {
+	return Packet{Id: SAssignment, Data: struct {
+		Opens   int64  `json:"opens"`             // The unix time in milliseconds the game opens
+		Closes  int64  `json:"closes"`            // The unix time in milliseconds the game closes
+		Results string `json:"results,omitempty"` // The token for downloading the results
+	}{Opens: opens.UnixMilli(), Closes: closes.UnixMilli(), Results: results}}
+}
+
+// SpectatorsPacket creates a new spectators packet which tells the host how
+// many spectators are watching the game
+func SpectatorsPacket(count int) Packet {
+	return Packet{Id: SSpectators, Data: struct {
+		Count int `json:"count"` // The number of spectators watching
+	}{Count: count}}
+}
@@ -1,7 +1,9 @@
 package tools
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"math"
+	"math/big"
 	"os"
 	"time"
 )
@@ -25,6 +27,10 @@ type (
 		Question string        // The actual contents of the question
 		Answers  []string      // The possible answer values
 		Values   []AnswerIndex // The indexes of the correct answers
+		Pool     string        // Optional - the pool the question can be drawn from
+
+		Explanation string // Optional - shown to players when the question is marked
+		Notes       string // Optional - private notes only sent to the host
 	}
 
 	// ScoreMap A map of player identifiers to score values
@@ -48,19 +54,55 @@ func (question *QuestionData) FreeMemory() {
 	question.Image = ""
 }
 
+// IsCorrect checks the correct answers for the question and checks if they
+// match the provided answer index
+func (question *QuestionData) IsCorrect(answer AnswerIndex) bool {
+	for _, value := range question.Values { // Iterate over the correct answers
+		if value == answer { // If it matches
+			return true // The answer is correct
+		}
+	}
+	return false
+}
+
+// HexChars The chars used by CreateRandomId
+const HexChars = "ABCDEF0123456789"
+
 // CreateRandomId Creates a random identifier of the specified length using
 // the chars from A-F and numbers 0 to 9
-func CreateRandomId(length uint8) Identifier {
-	chars := []rune("ABCDEF0123456789") // Define the available chars
-	out := make([]rune, length)         // Create a new rune array of the provided length
-	for i := range out {                // For every index of the rune array
-		// Pick a random char from the available chars and set it
-		out[i] = chars[rand.Intn(16)]
+func CreateRandomId(length int) Identifier {
+	return CreateRandomIdFrom(HexChars, length)
+}
+
+// CreateRandomIdFrom Creates a random identifier of the specified length using
+// the provided chars. The chars are picked using a cryptographically secure
+// random source so that the identifiers can't be predicted
+func CreateRandomIdFrom(chars string, length int) Identifier {
+	available := []rune(chars)               // Split the available chars
+	max := big.NewInt(int64(len(available))) // The number of chars to pick from
+	out := make([]rune, length)              // Create a new rune array of the provided length
+	for i := range out {                     // For every index of the rune array
+		index, err := rand.Int(rand.Reader, max) // Pick a random char from the available chars
+		if err != nil {                          // The secure random source should never fail
+			panic(err)
+		}
+		out[i] = available[index.Int64()]
 	}
 	// Return the new identifier
 	return Identifier(out)
 }
 
+// RandomSeed Creates a random seed for seeding pseudo random sources. The seed
+// is picked using a cryptographically secure random source so that the order
+// of anything shuffled with it can't be predicted
+func RandomSeed() int64 {
+	seed, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil { // The secure random source should never fail
+		panic(err)
+	}
+	return seed.Int64()
+}
+
 // Time Retrieves the current time in milliseconds
 func Time() time.Duration {
 	return time.Duration(time.Now().UnixNano())